@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math"
+)
+
+// Overlay is drawn on top of the price series and can take part in auto-fit
+type Overlay interface {
+	Draw(screen Renderer, chart *Chart)
+	// PriceRange reports the overlay's extent between two bar times
+	PriceRange(from, to int64) (min, max float64, ok bool)
+}
+
+// AddOverlay attaches an overlay to the price pane
+func (c *Chart) AddOverlay(o Overlay) {
+	c.overlays = append(c.overlays, o)
+	c.overlaysRev++
+	c.fitPriceRange()
+}
+
+// RemoveOverlay detaches an overlay from the price pane
+func (c *Chart) RemoveOverlay(o Overlay) {
+	for i, other := range c.overlays {
+		if other == o {
+			c.overlays = append(c.overlays[:i], c.overlays[i+1:]...)
+			c.overlaysRev++
+			c.fitPriceRange()
+			return
+		}
+	}
+}
+
+// ToggleLock freezes the displayed price range or returns it to auto-fit
+func (c *Chart) ToggleLock() {
+	if c.autoScale {
+		c.autoScale = false
+		return
+	}
+	c.ResetPriceView()
+}
+
+// ResetPriceView returns the vertical viewport to auto-fit
+func (c *Chart) ResetPriceView() {
+	c.autoScale = true
+	c.fitPriceRange()
+}
+
+// viewportChanged refreshes everything that depends on the visible bars
+func (c *Chart) viewportChanged() {
+	c.fitPriceRange()
+	c.updateScaleBase()
+}
+
+// fitPriceRange sets priceMin/priceMax to the visible bars plus padding and,
+// while auto-fit is on, moves the vertical viewport onto it
+func (c *Chart) fitPriceRange() {
+	start, end := c.visibleRange()
+	if start == -1 || end <= start {
+		return
+	}
+
+	min, max := calculatePriceRange(c.Data[start:end])
+	if c.config.FitOverlays {
+		from, to := c.Data[start].Time, c.Data[end-1].Time
+		for _, o := range c.overlays {
+			if oMin, oMax, ok := o.PriceRange(from, to); ok {
+				min = math.Min(min, oMin)
+				max = math.Max(max, oMax)
+			}
+		}
+	}
+
+	padding := (max - min) * c.config.PricePadding
+	if padding == 0 {
+		// Flat range, open it up around the price
+		padding = math.Max(math.Abs(max)*0.01, 1e-8)
+	}
+	c.priceMin, c.priceMax = min-padding, max+padding
+	if c.Scale.Mode == ScaleLog && c.priceMin <= 0 {
+		c.priceMin = min / 2
+	}
+	if c.autoScale {
+		c.viewMin, c.viewMax = c.priceMin, c.priceMax
+	}
+}
@@ -3,187 +3,303 @@ package main
 import (
 	"fmt"
 	"math"
-	"time"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/text"
-	"github.com/hajimehoshi/ebiten/v2/vector"
 	"golang.org/x/image/font"
-	"golang.org/x/image/font/basicfont"
 )
 
 type Axes struct {
+	fonts       *fontCache
 	fontFace    font.Face
-	lastMonth   int
 	labelHeight int
+	timeTicks   []timeTick
 	config      ChartConfig
+	layout      *Layout
 }
 
-func NewAxes(config ChartConfig) *Axes {
-	face := basicfont.Face7x13
-	metrics := face.Metrics()
-	textHeight := metrics.Ascent.Ceil() + metrics.Descent.Ceil()
+func NewAxes(config ChartConfig, layout *Layout) *Axes {
 	return &Axes{
-		fontFace:    face,
-		lastMonth:   -1,
-		labelHeight: textHeight,
-		config:      config,
+		fonts:  newFontCache(12),
+		config: config,
+		layout: layout,
 	}
 }
 
 func (a *Axes) Update(chart *Chart) {
-	a.lastMonth = -1
+	a.fontFace = a.fonts.get(a.layout)
+	a.labelHeight = a.fonts.height
+	a.timeTicks = computeTimeTicks(chart, a.layout.MinTimeLabelSpacing)
 }
 
-func (a *Axes) Draw(screen *ebiten.Image, chart *Chart) {
-	// Calculate chart dimensions
-	chartWidth := a.config.Width - a.config.LeftMargin - a.config.RightMargin
-	chartHeight := a.config.Height - a.config.TopMargin - a.config.BottomMargin
-	timeRange := chart.timeEnd - chart.timeStart
-	timeStep := timeRange / 12 // 12 vertical divisions
+// axisTick is a horizontal grid line with its value label
+type axisTick struct {
+	y     float32
+	label string
+}
+
+// minLabelSpacing is the closest two value labels may be
+func (a *Axes) minLabelSpacing() float64 {
+	return float64(a.labelHeight) * a.config.MinLabelSpacing
+}
+
+// priceTicks returns the price pane's grid lines, dropping any closer than
+// the minimum label spacing to the one above
+func (a *Axes) priceTicks(chart *Chart) []axisTick {
+	minSpacing := a.minLabelSpacing()
+	targetLabels := max(a.config.MinPriceLabels, int(chart.panes.Main().Height()/minSpacing))
+	prevY := math.Inf(-1)
+
+	var ticks []axisTick
+	prices, step := chart.Scale.ticks(chart.viewMin, chart.viewMax, targetLabels)
+	for _, price := range prices {
+		y := float64(chart.priceToY(price))
+		if !chart.panes.Main().Contains(y) {
+			continue
+		}
+		if prevY == math.Inf(-1) || math.Abs(prevY-y) >= minSpacing {
+			ticks = append(ticks, axisTick{float32(y), chart.Scale.label(price, step)})
+			prevY = y
+		}
+	}
+	return ticks
+}
+
+// paneTicks returns the grid lines of an indicator pane
+func (a *Axes) paneTicks(pane *Pane) []axisTick {
+	var ticks []axisTick
+	step := calculateStep(pane.max-pane.min, max(2, int(pane.Height()/a.minLabelSpacing())))
+	for v := math.Ceil(pane.min/step) * step; v <= pane.max; v += step {
+		y := pane.valueToY(v)
+		if float64(y)-pane.Top < float64(a.labelHeight) {
+			continue // Keep clear of the pane name and splitter
+		}
+		ticks = append(ticks, axisTick{y, pane.Content.FormatValue(v, step)})
+	}
+	return ticks
+}
 
-	// Draw alternating vertical segments (dark/light)
-	for t := chart.timeStart; t < chart.timeEnd; t += timeStep {
-		x1 := a.config.LeftMargin + (float64(t-chart.timeStart) / float64(timeRange) * chartWidth)
-		x2 := a.config.LeftMargin + (float64(t-chart.timeStart+timeStep/2) / float64(timeRange) * chartWidth)
+// DrawGrid draws the alternating time segments and the grid lines behind
+// the series
+func (a *Axes) DrawGrid(screen Renderer, chart *Chart) {
+	chartHeight := a.layout.Height - a.layout.TopMargin - a.layout.BottomMargin
+	chartRight := a.layout.Width - a.layout.RightMargin
 
-		// First segment (dark)
-		vector.DrawFilledRect(
-			screen,
+	// Draw alternating vertical segments (dark/light) between time ticks
+	for k := -1; k < len(a.timeTicks); k++ {
+		x1, x2 := a.layout.LeftMargin, chartRight
+		var ordinal int64
+		if k >= 0 {
+			x1 = a.timeTicks[k].x
+			ordinal = a.timeTicks[k].ordinal
+		} else if len(a.timeTicks) > 0 {
+			ordinal = a.timeTicks[0].ordinal - 1
+		}
+		if k+1 < len(a.timeTicks) {
+			x2 = a.timeTicks[k+1].x
+		}
+
+		segmentColor := a.config.PrimaryGridColor
+		if ordinal%2 != 0 {
+			segmentColor = a.config.SecondaryGridColor
+		}
+		screen.FillRect(
 			float32(x1),
-			float32(a.config.TopMargin),
+			float32(a.layout.TopMargin),
 			float32(x2-x1),
 			float32(chartHeight),
-			a.config.PrimaryGridColor,
-			false,
+			segmentColor,
 		)
+	}
 
-		// Second segment (light)
-		x3 := a.config.LeftMargin + (float64(t-chart.timeStart+timeStep) / float64(timeRange) * chartWidth)
-		if x3 > a.config.Width-a.config.RightMargin {
-			x3 = a.config.Width - a.config.RightMargin
+	// Horizontal grid lines of the price pane and the indicator panes
+	ticks := a.priceTicks(chart)
+	for _, pane := range chart.panes.All() {
+		if pane.Content != nil && pane.Height() > 0 {
+			ticks = append(ticks, a.paneTicks(pane)...)
 		}
-		vector.DrawFilledRect(
-			screen,
-			float32(x2),
-			float32(a.config.TopMargin),
-			float32(x3-x2),
-			float32(chartHeight),
-			a.config.SecondaryGridColor,
-			false,
+	}
+	for _, tick := range ticks {
+		screen.StrokeLine(
+			float32(a.layout.LeftMargin), tick.y,
+			float32(chartRight), tick.y,
+			a.layout.Px32(0.5),
+			a.config.GridColor,
 		)
 	}
 
+	// Vertical grid lines at the time ticks
+	for _, tick := range a.timeTicks {
+		screen.StrokeLine(
+			float32(tick.x), float32(a.layout.TopMargin),
+			float32(tick.x), float32(a.layout.Height-a.layout.BottomMargin),
+			a.layout.GridWidth,
+			a.config.GridColor,
+		)
+	}
+}
+
+// DrawLabels draws the axis lines, the price, value and time labels and the
+// right-hand axis markers over the series
+func (a *Axes) DrawLabels(screen Renderer, chart *Chart) {
 	// Draw Y axis
-	vector.StrokeLine(
-		screen,
-		float32(a.config.LeftMargin),
-		float32(a.config.TopMargin),
-		float32(a.config.LeftMargin),
-		float32(a.config.Height-a.config.BottomMargin),
-		a.config.AxisWidth,
+	screen.StrokeLine(
+		float32(a.layout.LeftMargin),
+		float32(a.layout.TopMargin),
+		float32(a.layout.LeftMargin),
+		float32(a.layout.Height-a.layout.BottomMargin),
+		a.layout.AxisWidth,
 		a.config.AxisColor,
-		false,
 	)
 
-	// Draw price labels and horizontal grid lines
-	priceRange := chart.priceMax - chart.priceMin
-	priceStep := calculateStep(priceRange/float64(a.config.MinPriceLabels), a.config.MinPriceLabels)
-	minSpacing := float64(a.labelHeight) * a.config.MinLabelSpacing
-	prevY := math.Inf(-1)
-
-	for price := math.Ceil(chart.priceMin/priceStep) * priceStep; price <= chart.priceMax; price += priceStep {
-		y := a.config.Height - a.config.BottomMargin - ((price - chart.priceMin) / priceRange * chartHeight)
-
-		// Ensure minimum spacing between labels
-		if prevY == math.Inf(-1) || (prevY-y) >= minSpacing {
-			// Draw horizontal grid line
-			vector.StrokeLine(
-				screen,
-				float32(a.config.LeftMargin), float32(y),
-				float32(a.config.Width-a.config.RightMargin), float32(y),
-				0.5,
-				a.config.GridColor,
-				false,
-			)
-			// Draw price label
-			priceStr := formatPriceLabel(price)
-			textWidth := font.MeasureString(a.fontFace, priceStr).Ceil()
-			text.Draw(
-				screen,
-				priceStr,
-				a.fontFace,
-				int(a.config.LeftMargin)-textWidth-10, // 10px padding from axis
-				int(y)+a.labelHeight/2,
-				a.config.LabelColor,
-			)
-			prevY = y
-		}
-	}
+	a.drawTickLabels(screen, a.priceTicks(chart))
+	a.drawPaneAxes(screen, chart)
 
 	// Draw X axis
-	vector.StrokeLine(
-		screen,
-		float32(a.config.LeftMargin),
-		float32(a.config.Height-a.config.BottomMargin),
-		float32(a.config.Width-a.config.RightMargin),
-		float32(a.config.Height-a.config.BottomMargin),
-		a.config.AxisWidth,
+	screen.StrokeLine(
+		float32(a.layout.LeftMargin),
+		float32(a.layout.Height-a.layout.BottomMargin),
+		float32(a.layout.Width-a.layout.RightMargin),
+		float32(a.layout.Height-a.layout.BottomMargin),
+		a.layout.AxisWidth,
 		a.config.AxisColor,
-		false,
 	)
 
-	// Draw time labels (on top of the segments)
-	for t := chart.timeStart; t <= chart.timeEnd; t += timeStep {
-		x := a.config.LeftMargin + (float64(t-chart.timeStart) / float64(timeRange) * chartWidth)
+	// Draw time labels
+	for _, tick := range a.timeTicks {
+		textWidth := font.MeasureString(a.fontFace, tick.label).Ceil()
+		x, y := int(tick.x)-textWidth/2, int(a.layout.Height-a.layout.Px(20))
+		screen.Text(tick.label, a.fontFace, x, y, a.config.LabelColor)
+		if tick.major {
+			// Overdraw one pixel to the right for a bold face
+			screen.Text(tick.label, a.fontFace, x+int(a.layout.Scale), y, a.config.LabelColor)
+		}
+	}
 
-		// Draw vertical grid line
-		vector.StrokeLine(
-			screen,
-			float32(x), float32(a.config.TopMargin),
-			float32(x), float32(a.config.Height-a.config.BottomMargin),
-			1.2,
-			a.config.GridColor,
-			false,
-		)
+	if a.config.ShowRightAxis {
+		a.drawRightAxis(screen, chart)
+	}
+}
 
-		// Draw time label - handle milliseconds
-		tm := time.Unix(t/1000, (t%1000)*int64(time.Millisecond))
-		currentMonth := int(tm.Month())
+// DrawInsetLabels labels the scale of a band drawn inside the price pane,
+// such as the volume overlay, just inside the left axis
+func (a *Axes) DrawInsetLabels(screen Renderer, band *Pane) {
+	for _, tick := range a.paneTicks(band) {
+		screen.Text(tick.label, a.fontFace, int(a.layout.LeftMargin+a.layout.Px(6)), int(tick.y)+a.labelHeight/2, a.config.LabelColor)
+	}
+}
+
+// drawTickLabels labels grid lines beside the left axis, and the right one
+// when shown
+func (a *Axes) drawTickLabels(screen Renderer, ticks []axisTick) {
+	chartRight := a.layout.Width - a.layout.RightMargin
+	for _, tick := range ticks {
+		textWidth := font.MeasureString(a.fontFace, tick.label).Ceil()
+		y := int(tick.y) + a.labelHeight/2
+		screen.Text(tick.label, a.fontFace, int(a.layout.LeftMargin-a.layout.Px(10))-textWidth, y, a.config.LabelColor) // 10px padding from axis
+		if a.config.ShowRightAxis {
+			screen.Text(tick.label, a.fontFace, int(chartRight+a.layout.Px(6)), y, a.config.LabelColor)
+		}
+	}
+}
 
-		var timeText string
-		if currentMonth != a.lastMonth {
-			timeText = tm.Format("Jan 2")
-			a.lastMonth = currentMonth
-		} else {
-			timeText = tm.Format("15:04")
+// drawPaneAxes draws the separator, name and value labels of each
+// indicator pane
+func (a *Axes) drawPaneAxes(screen Renderer, chart *Chart) {
+	chartRight := a.layout.Width - a.layout.RightMargin
+	for _, pane := range chart.panes.All() {
+		if pane.Content == nil || pane.Height() <= 0 {
+			continue
 		}
 
-		textWidth := font.MeasureString(a.fontFace, timeText).Ceil()
-		text.Draw(
-			screen,
-			timeText,
+		screen.StrokeLine(
+			float32(a.layout.LeftMargin), float32(pane.Top),
+			float32(chartRight), float32(pane.Top),
+			a.layout.AxisWidth,
+			a.config.AxisColor,
+		)
+		screen.Text(
+			pane.Content.Name(),
 			a.fontFace,
-			int(x)-textWidth/2,
-			int(a.config.Height)-20,
+			int(a.layout.LeftMargin+a.layout.Px(6)),
+			int(pane.Top)+a.labelHeight,
 			a.config.LabelColor,
 		)
+		a.drawTickLabels(screen, a.paneTicks(pane))
+	}
+}
+
+// calculateStep returns a 1-2-5 nice step that splits rangeSize into about
+// targetLabels intervals
+func calculateStep(rangeSize float64, targetLabels int) float64 {
+	if rangeSize <= 0 || targetLabels < 1 {
+		return 1
+	}
+	raw := rangeSize / float64(targetLabels)
+	power := math.Pow(10, math.Floor(math.Log10(raw)))
+	switch fraction := raw / power; {
+	case fraction <= 1:
+		return power
+	case fraction <= 2:
+		return 2 * power
+	case fraction <= 5:
+		return 5 * power
+	default:
+		return 10 * power
+	}
+}
+
+// stepDecimals returns the decimals needed to tell ticks step apart, never
+// finer than the symbol's tick size
+func stepDecimals(step, tickSize float64) int {
+	decimals := int(math.Max(0, math.Ceil(-math.Log10(step)-1e-9)))
+	if tickSize > 0 {
+		decimals = min(decimals, int(math.Max(0, math.Ceil(-math.Log10(tickSize)-1e-9))))
+	}
+	return decimals
+}
+
+// maxTickDecimals is the finest tick size inferTickSize looks for
+const maxTickDecimals = 8
+
+// inferTickSize returns the price increment the bars are quoted in: the
+// coarsest power of ten every price is a multiple of. Prices on no such
+// grid, as computed series have, get five significant digits of the
+// largest price. It returns 0 when the bars give nothing to go on.
+func inferTickSize(bars []OHLCV) float64 {
+	decimals, largest := 0, 0.0
+	for _, b := range bars {
+		for _, p := range [...]float64{b.Open, b.High, b.Low, b.Close} {
+			for decimals <= maxTickDecimals && !onDecimalGrid(p, decimals) {
+				decimals++
+			}
+			largest = math.Max(largest, math.Abs(p))
+		}
+	}
+	switch {
+	case largest == 0:
+		return 0
+	case decimals <= maxTickDecimals:
+		return math.Pow(10, -float64(decimals))
+	default:
+		return math.Pow(10, math.Floor(math.Log10(largest))-4)
 	}
 }
 
-func calculateStep(rangeSize float64, minLabels int) float64 {
-	exponent := math.Floor(math.Log10(rangeSize))
-	power := math.Pow(10, exponent)
-	return power
+// onDecimalGrid reports whether price has at most decimals decimal places,
+// allowing for floating point error
+func onDecimalGrid(price float64, decimals int) bool {
+	scaled := price * math.Pow(10, float64(decimals))
+	return math.Abs(scaled-math.Round(scaled)) <= 1e-9*math.Max(1, math.Abs(scaled))
 }
 
-func formatPriceLabel(price float64) string {
+// formatPriceLabel renders an axis price with just enough precision for step,
+// abbreviating thousands and millions when the step allows it
+func formatPriceLabel(price, step, tickSize float64) string {
 	switch {
-	case price >= 1000000:
-		return fmt.Sprintf("%.1fM", price/1000000)
-	case price >= 1000:
-		return fmt.Sprintf("%.0fK", price/1000)
+	case step >= 100000 && math.Abs(price) >= 1000000:
+		return fmt.Sprintf("%.*fM", stepDecimals(step/1000000, 0), price/1000000)
+	case step >= 100 && math.Abs(price) >= 10000:
+		return fmt.Sprintf("%.*fK", stepDecimals(step/1000, 0), price/1000)
 	default:
-		return fmt.Sprintf("%.0f", price)
+		return fmt.Sprintf("%.*f", stepDecimals(step, tickSize), price)
 	}
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// quadsPerCall is the most rectangles one DrawTriangles call can take, as
+// its indices are 16-bit
+const quadsPerCall = (1 << 16) / 4
+
+// quadBatch collects axis-aligned rectangles so a layer of bars is drawn in
+// one go. The Ebiten backend turns them into one vertex and index buffer,
+// drawn with a single DrawTriangles call per quadsPerCall rectangles. The
+// buffers are kept between rebuilds to avoid allocating.
+type quadBatch struct {
+	rects    []batchRect
+	vertices []ebiten.Vertex
+	indices  []uint16
+	built    bool // vertices and indices match rects
+}
+
+type batchRect struct {
+	x, y, width, height float32
+	clr                 color.RGBA
+}
+
+// reset empties the batch, keeping its buffers
+func (b *quadBatch) reset() {
+	b.rects = b.rects[:0]
+	b.built = false
+}
+
+// addRect appends a filled rectangle
+func (b *quadBatch) addRect(x, y, width, height float32, clr color.RGBA) {
+	b.rects = append(b.rects, batchRect{x, y, width, height, clr})
+	b.built = false
+}
+
+// addLine appends a vertical or horizontal line of the given width centred
+// on the segment, as vector.StrokeLine draws it
+func (b *quadBatch) addLine(x0, y0, x1, y1, width float32, clr color.RGBA) {
+	if x0 == x1 {
+		b.addRect(x0-width/2, min32(y0, y1), width, max32(y0, y1)-min32(y0, y1), clr)
+		return
+	}
+	b.addRect(min32(x0, x1), y0-width/2, max32(x0, x1)-min32(x0, x1), width, clr)
+}
+
+// build fills the vertex and index buffers from the rectangles
+func (b *quadBatch) build() {
+	b.vertices = b.vertices[:0]
+	b.indices = b.indices[:0]
+	for _, rect := range b.rects {
+		r, g, bl, a := rect.clr.RGBA()
+		cr, cg, cb, ca := float32(r)/0xffff, float32(g)/0xffff, float32(bl)/0xffff, float32(a)/0xffff
+		x0, y0, x1, y1 := rect.x, rect.y, rect.x+rect.width, rect.y+rect.height
+		base := uint16(len(b.vertices) % (quadsPerCall * 4))
+		for _, p := range [4][2]float32{{x0, y0}, {x1, y0}, {x0, y1}, {x1, y1}} {
+			b.vertices = append(b.vertices, ebiten.Vertex{
+				DstX: p[0], DstY: p[1],
+				SrcX: 1, SrcY: 1,
+				ColorR: cr, ColorG: cg, ColorB: cb, ColorA: ca,
+			})
+		}
+		b.indices = append(b.indices, base, base+1, base+2, base+1, base+3, base+2)
+	}
+	b.built = true
+}
+
+// draw submits the batch to dst
+func (b *quadBatch) draw(dst *ebiten.Image) {
+	if !b.built {
+		b.build()
+	}
+	op := &ebiten.DrawTrianglesOptions{}
+	op.ColorScaleMode = ebiten.ColorScaleModePremultipliedAlpha
+	src := whiteImage.SubImage(whiteImage.Bounds().Inset(1)).(*ebiten.Image)
+	for v, i := 0, 0; v < len(b.vertices); v, i = v+quadsPerCall*4, i+quadsPerCall*6 {
+		vEnd, iEnd := min(v+quadsPerCall*4, len(b.vertices)), min(i+quadsPerCall*6, len(b.indices))
+		dst.DrawTriangles(b.vertices[v:vEnd], b.indices[i:iEnd], src, op)
+	}
+}
+
+// geometryKey captures everything a layer of bar geometry depends on, so
+// the batch is rebuilt only when the data or the viewport changes
+type geometryKey struct {
+	data       *OHLCV // First bar, identifies the slice
+	length     int
+	last       OHLCV // The last bar changes while it is forming
+	start, end int
+	zoom       float64
+	scroll     float64
+	lo, hi     float64 // Value range mapped onto the pane
+	scale      PriceScale
+	top        float64
+	bottom     float64
+	width      float64
+	dpi        float64
+	style      SeriesStyle
+	theme      string
+}
+
+// geometryKey returns the key for bars start..end-1 drawn between top and
+// bottom with lo..hi mapped onto them
+func (c *Chart) geometryKey(start, end int, top, bottom, lo, hi float64) geometryKey {
+	key := geometryKey{
+		length: len(c.Data),
+		start:  start,
+		end:    end,
+		zoom:   c.Zoom,
+		scroll: c.scroll,
+		lo:     lo,
+		hi:     hi,
+		scale:  c.Scale,
+		top:    top,
+		bottom: bottom,
+		width:  c.layout.Width,
+		dpi:    c.layout.Scale,
+		style:  c.Style,
+		theme:  c.config.Theme,
+	}
+	if len(c.Data) > 0 {
+		key.data, key.last = &c.Data[0], c.Data[len(c.Data)-1]
+	}
+	return key
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// benchBarCounts are the visible bar counts the benchmarks compare
+var benchBarCounts = []int{10000, 20000}
+
+// benchWidth is the width of the offscreen chart the benchmarks draw to
+const benchWidth = 4000
+
+// TestMain runs the tests from inside the game loop when benchmarks are
+// asked for, since they draw to images. Other tests need no display.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if f := flag.Lookup("test.bench"); f == nil || f.Value.String() == "" {
+		os.Exit(m.Run())
+	}
+	g := &testGame{m: m}
+	if err := ebiten.RunGame(g); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(g.code)
+}
+
+// testGame runs the tests in its first update and exits
+type testGame struct {
+	m    *testing.M
+	code int
+}
+
+func (g *testGame) Update() error {
+	g.code = g.m.Run()
+	return ebiten.Termination
+}
+
+func (g *testGame) Draw(screen *ebiten.Image) {}
+
+func (g *testGame) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return outsideWidth, outsideHeight
+}
+
+// benchScene is a chart with a volume pane and the image it is drawn to
+type benchScene struct {
+	chart  *Chart
+	volume *Volume
+	pane   *Pane
+	img    *ebiten.Image
+	screen Renderer
+}
+
+// benchFrames times draw at each of benchBarCounts visible bars
+func benchFrames(b *testing.B, draw func(s *benchScene)) {
+	for _, n := range benchBarCounts {
+		b.Run(fmt.Sprintf("%dbars", n), func(b *testing.B) {
+			chart, volume, img := benchChart(n)
+			defer img.Deallocate()
+			s := &benchScene{chart, volume, chart.panes.Find(volume), img, newEbitenRenderer(img)}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				draw(s)
+			}
+		})
+	}
+}
+
+// BenchmarkPerBar draws the candles and volume with a vector call per shape
+func BenchmarkPerBar(b *testing.B) {
+	benchFrames(b, func(s *benchScene) {
+		drawCandlesVector(s.img, s.chart)
+		drawVolumeVector(s.img, s.chart, s.pane)
+	})
+}
+
+// BenchmarkBatched draws them as batched geometry rebuilt every frame
+func BenchmarkBatched(b *testing.B) {
+	benchFrames(b, func(s *benchScene) {
+		s.chart.barsKey, s.volume.key = geometryKey{}, volumeKey{}
+		s.chart.Draw(s.screen)
+		s.volume.Draw(s.screen, s.chart, s.pane)
+	})
+}
+
+// BenchmarkBatchedReused draws the batched geometry built in an earlier
+// frame, as an unchanged viewport does
+func BenchmarkBatchedReused(b *testing.B) {
+	benchFrames(b, func(s *benchScene) {
+		s.chart.Draw(s.screen)
+		s.volume.Draw(s.screen, s.chart, s.pane)
+	})
+}
+
+// benchChart returns a candle chart with a volume pane showing n random-walk
+// bars, and an image to draw it to
+func benchChart(n int) (*Chart, *Volume, *ebiten.Image) {
+	config := DefaultConfig
+	DarkTheme.Apply(&config)
+	config.Width = benchWidth
+	layout := NewLayout(config)
+	chart := NewChart(config, layout)
+	volume := NewVolume(config, layout)
+	volume.MAPeriod = 0 // Bars only, as drawVolumeVector draws
+	chart.panes.Add(volume, 1)
+
+	visibleWidth := layout.Width - layout.LeftMargin - layout.RightMargin
+	chart.Zoom = visibleWidth / (float64(n) * (layout.BarWidth + layout.BarSpacing))
+
+	rng := rand.New(rand.NewSource(1))
+	data := make([]OHLCV, n)
+	price := 30000.0
+	for i := range data {
+		open := price
+		price *= 1 + rng.NormFloat64()*0.002
+		data[i] = OHLCV{
+			Time:   int64(i) * defaultBarInterval,
+			Open:   open,
+			High:   math.Max(open, price) * (1 + rng.Float64()*0.001),
+			Low:    math.Min(open, price) * (1 - rng.Float64()*0.001),
+			Close:  price,
+			Volume: rng.Float64() * 100,
+		}
+	}
+	chart.UpdateData(data)
+	chart.panes.fitRanges(chart)
+
+	return chart, volume, ebiten.NewImage(int(layout.Width), int(layout.Height))
+}
+
+// drawCandlesVector draws the visible candles with a vector call per wick
+// and body, as the chart did before batching. It draws the same bars per
+// pixel column as the batched path, so only the submission differs.
+func drawCandlesVector(screen *ebiten.Image, c *Chart) {
+	start, end := c.visibleRange()
+	bodyWidth := c.bodyWidth()
+	for _, d := range c.visibleBars(start, end) {
+		body, wick := c.barColors(d.OHLCV)
+		lowY, highY := c.priceToY(d.bodyLow), c.priceToY(d.bodyHigh)
+		bodyTop, bodyBottom := min32(lowY, highY), max32(lowY, highY)
+		if bodyBottom-bodyTop < 1 {
+			bodyBottom = bodyTop + 1
+		}
+		vector.StrokeLine(screen, d.x, c.priceToY(d.High), d.x, c.priceToY(d.Low), float32(c.layout.BarWidth), wick, false)
+		vector.DrawFilledRect(screen, d.x-bodyWidth/2, bodyTop, bodyWidth, bodyBottom-bodyTop, body, false)
+	}
+}
+
+// drawVolumeVector draws the visible volume with a vector call per bar,
+// over the same bars as drawCandlesVector
+func drawVolumeVector(screen *ebiten.Image, c *Chart, pane *Pane) {
+	start, end := c.visibleRange()
+	width := float32(math.Max(1, c.layout.BarSpacing*c.Zoom-c.layout.VolumeSpacing))
+	for _, d := range c.visibleBars(start, end) {
+		y := pane.valueToY(d.Volume)
+		clr := c.config.VolumeUpColor
+		if d.Close < d.Open {
+			clr = c.config.VolumeDownColor
+		}
+		vector.DrawFilledRect(screen, d.x-width/2, y, width, float32(pane.Bottom)-y, clr, false)
+	}
+}
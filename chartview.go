@@ -0,0 +1,374 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// ChartSpec names what one chart in the grid shows
+type ChartSpec struct {
+	Symbol   string // Stored symbol, synthetic name or expression
+	Interval time.Duration
+	Compare  []string  // Symbols drawn over the chart as compare series
+	From, To time.Time // Fixed range to load, zero follows the latest bars
+	TickSize float64   // Price increment, 0 infers it from the bars
+}
+
+// ChartView is one chart with its components, drawn to its own offscreen
+// image so several can share the window
+type ChartView struct {
+	spec            ChartSpec
+	layout          *Layout
+	chart           *Chart
+	axes            *Axes
+	interaction     *Interaction
+	selection       *SelectionView
+	volume          *Volume
+	volumePlacement VolumePlacement
+	profile         ProfileKind
+	volumeProfile   *VolumeProfile // Created when first shown
+	marketProfile   *MarketProfile // Created when first shown
+	minutes         *minuteCache   // Minute bars the profiles share
+	compares        []*Compare
+	legendFonts     *fontCache
+	source          MinuteSource
+	stores          []*Database // Stores the source reads from
+	compareStores   []*Database // Stores the compares read from
+	timeframe       *Timeframe
+	image           *ebiten.Image // The layers composited under the crosshair
+	layers          chartLayers
+	prompt          *datePrompt // Go to date box, nil while closed
+	gestures        gestureRecognizer
+	lastUpdate      time.Time
+	needsRedraw     bool
+	prevFetchStatus string
+	prevFetching    bool
+	prevErrorMsg    string
+	config          ChartConfig
+}
+
+func NewChartView(config ChartConfig, spec ChartSpec, source MinuteSource, stores []*Database) *ChartView {
+	layout := NewLayout(config)
+	chart := NewChart(config, layout)
+	volume := NewVolume(config, layout)
+
+	timeframe := NewTimeframe(source, spec.Interval)
+	timeframe.Session = config.session()
+	if !spec.To.IsZero() {
+		timeframe.From, timeframe.To = spec.From.UnixMilli(), spec.To.UnixMilli()
+	}
+	chart.SetInterval(timeframe.Interval)
+
+	v := &ChartView{
+		spec:        spec,
+		layout:      layout,
+		chart:       chart,
+		axes:        NewAxes(config, layout),
+		interaction: NewInteraction(config, layout),
+		selection:   NewSelectionView(config, layout),
+		volume:      volume,
+		legendFonts: newFontCache(12),
+		source:      source,
+		stores:      stores,
+		timeframe:   timeframe,
+		lastUpdate:  time.Now(),
+		needsRedraw: true, // Ensure initial render
+		config:      config,
+	}
+	v.gestures.LongPress = config.LongPress
+	v.setVolumePlacement(config.VolumePlacement)
+	v.loadBars()
+	v.setProfile(config.Profile)
+	return v
+}
+
+// loadBars reads the view's bars from the store and merges them into the
+// chart so a refresh keeps the viewport. A store still being filled in the
+// background is read again once its fetch finishes.
+func (v *ChartView) loadBars() {
+	data, err := v.timeframe.GetBars()
+	if err != nil {
+		log.Printf("Failed to load %s bars: %v", v.spec.Symbol, err)
+		return
+	}
+	v.chart.MergeData(data)
+	v.setTickSize()
+	v.loadCompares()
+	v.needsRedraw = true
+}
+
+// setTickSize gives the price scale the symbol's tick size: the spec's, or
+// else the one the loaded bars are quoted in
+func (v *ChartView) setTickSize() {
+	tick := v.spec.TickSize
+	if tick == 0 {
+		tick = inferTickSize(v.chart.Data)
+	}
+	if tick > 0 {
+		v.chart.Scale.TickSize = tick
+	}
+}
+
+// AddCompare draws db's symbol over the chart. The first compare puts the
+// chart on a percent scale so the lines read as change.
+func (v *ChartView) AddCompare(symbol string, source MinuteSource, stores []*Database) {
+	colors := v.config.CompareColors
+	clr := v.config.LineColor
+	if len(colors) > 0 {
+		clr = colors[len(v.compares)%len(colors)]
+	}
+	cmp := NewCompare(v.config, v.layout, v.chart, symbol, source, clr)
+	if err := cmp.Load(); err != nil {
+		log.Printf("Failed to load compare bars: %v", err)
+	}
+	v.compareStores = append(v.compareStores, stores...)
+	if len(v.compares) == 0 {
+		v.chart.Scale.Mode = ScalePercent
+	}
+	v.compares = append(v.compares, cmp)
+	v.chart.AddOverlay(cmp)
+	v.chart.updateScaleBase()
+	v.needsRedraw = true
+}
+
+// Place positions the view at x, y in the window with the given size in
+// device pixels
+func (v *ChartView) Place(x, y, width, height, scale float64) {
+	prevWidth, prevHeight := v.layout.Width, v.layout.Height
+	v.layout.SetOrigin(x, y)
+	v.layout.Resize(width/scale, height/scale, scale)
+	if v.layout.Width != prevWidth || v.layout.Height != prevHeight {
+		v.chart.viewportChanged()
+		v.needsRedraw = true
+	}
+}
+
+// Update runs the view's components. Input is read relative to the view, so
+// views not under the cursor ignore it.
+func (v *ChartView) Update(now time.Time) error {
+	// Check for changes in fetch status or error message
+	var currentFetchStatus, currentErrorMsg string
+	fetching := false
+	for _, stores := range [][]*Database{v.stores, v.compareStores} {
+		for _, db := range stores {
+			db.fetchMutex.Lock()
+			currentFetchStatus += db.fetchStatus
+			currentErrorMsg += db.errorMsg
+			fetching = fetching || db.fetching
+			db.fetchMutex.Unlock()
+		}
+	}
+
+	// Force redraw if fetching is in progress or status/error changed
+	if fetching || currentFetchStatus != v.prevFetchStatus || currentErrorMsg != v.prevErrorMsg {
+		v.needsRedraw = true
+		v.prevFetchStatus = currentFetchStatus
+		v.prevErrorMsg = currentErrorMsg
+	}
+	fetched := v.prevFetching && !fetching
+	v.prevFetching = fetching
+
+	// Reload once the background fetch has filled the stores, and
+	// periodically after that
+	if fetched || now.Sub(v.lastUpdate) > time.Minute {
+		v.loadBars()
+		v.lastUpdate = now
+	}
+
+	// Redraw the profile once the minutes it waits for are read
+	if v.minutes != nil && v.minutes.poll() {
+		v.chart.overlaysRev++
+		v.needsRedraw = true
+	}
+
+	if err := v.chart.Update(); err != nil {
+		return err
+	}
+	if v.chart.Gliding() {
+		v.needsRedraw = true
+	}
+	if v.chart.panes.Update(v.chart) {
+		v.needsRedraw = true
+	}
+	if v.volumePlacement == VolumeOverlay {
+		v.volume.fitOverlay(v.chart)
+	}
+	v.axes.Update(v.chart)
+	v.interaction.Update(v.chart)
+
+	if v.interaction.showCrosshair != v.interaction.prevShowCrosshair {
+		v.needsRedraw = true
+	}
+	return nil
+}
+
+// Hovered reports whether the cursor is over the view
+func (v *ChartView) Hovered() bool {
+	cx, cy := ebiten.CursorPosition()
+	return v.layout.Contains(cx, cy)
+}
+
+// Draw composites the view's layers and the crosshair into its offscreen
+// image if anything changed, redrawing only the layers whose inputs did
+func (v *ChartView) Draw() {
+	w, h := int(v.layout.Width), int(v.layout.Height)
+	if w <= 0 || h <= 0 {
+		return
+	}
+	if v.image == nil || v.image.Bounds().Dx() != w || v.image.Bounds().Dy() != h {
+		if v.image != nil {
+			v.image.Deallocate()
+		}
+		v.image = ebiten.NewImage(w, h)
+		v.needsRedraw = true
+	}
+	if !v.needsRedraw {
+		return
+	}
+
+	v.updateLayers(w, h)
+	v.image.Clear()
+	for _, l := range v.layers.all() {
+		l.drawTo(v.image)
+	}
+	screen := newEbitenRenderer(v.image)
+	v.selection.Draw(screen, v.chart)
+	v.interaction.Draw(screen, v.chart)
+	if v.prompt != nil {
+		v.prompt.Draw(screen, v.layout, v.config)
+	}
+	for _, db := range v.stores {
+		if db.errorMsg != "" {
+			db.DrawError(screen)
+			break
+		}
+	}
+	v.needsRedraw = false
+}
+
+// ApplyTheme recolours the view's components
+func (v *ChartView) ApplyTheme(theme Theme) {
+	theme.Apply(&v.config)
+	theme.Apply(&v.chart.config)
+	theme.Apply(&v.axes.config)
+	theme.Apply(&v.interaction.config)
+	theme.Apply(&v.selection.config)
+	theme.Apply(&v.volume.config)
+	if v.volumeProfile != nil {
+		theme.Apply(&v.volumeProfile.config)
+	}
+	if v.marketProfile != nil {
+		theme.Apply(&v.marketProfile.config)
+	}
+	for i, cmp := range v.compares {
+		theme.Apply(&cmp.config)
+		if colors := v.config.CompareColors; len(colors) > 0 {
+			cmp.Color = colors[i%len(colors)]
+		}
+	}
+	v.needsRedraw = true
+}
+
+// refreshStores fetches the latest minutes into each store
+func refreshStores(stores []*Database) {
+	for _, db := range stores {
+		if err := db.ensureLastData(); err != nil {
+			log.Printf("Failed to update database: %v", err)
+		}
+	}
+}
+
+// render draws the chart without the cursor-driven parts, so it can also
+// target the headless renderers
+func (v *ChartView) render(screen Renderer) {
+	v.drawGrid(screen)
+	v.drawBars(screen)
+	v.drawOverlays(screen)
+	v.drawLabels(screen)
+}
+
+func (v *ChartView) drawGrid(screen Renderer) {
+	screen.Fill(v.config.BackgroundColor)
+	v.axes.DrawGrid(screen, v.chart)
+}
+
+func (v *ChartView) drawBars(screen Renderer) {
+	v.chart.panes.DrawContent(screen, v.chart)
+	if pane := v.chart.panes.Main(); pane.Height() > 0 {
+		clip := screen.Clip(pane.Rect(v.layout))
+		if v.volumePlacement == VolumeOverlay {
+			v.volume.Draw(clip, v.chart, &v.volume.band)
+		}
+		v.chart.DrawSeries(clip)
+	}
+}
+
+func (v *ChartView) drawOverlays(screen Renderer) {
+	if pane := v.chart.panes.Main(); pane.Height() > 0 {
+		v.chart.DrawOverlays(screen.Clip(pane.Rect(v.layout)))
+	}
+}
+
+func (v *ChartView) drawLabels(screen Renderer) {
+	v.axes.DrawLabels(screen, v.chart)
+	if v.volumePlacement == VolumeOverlay && v.chart.panes.Main().Height() > 0 {
+		v.axes.DrawInsetLabels(screen, &v.volume.band)
+	}
+	v.drawLegend(screen)
+}
+
+// setProfile shows the market profile of the given kind over the price pane
+func (v *ChartView) setProfile(kind ProfileKind) {
+	if v.volumeProfile != nil {
+		v.chart.RemoveOverlay(v.volumeProfile)
+	}
+	if v.marketProfile != nil {
+		v.chart.RemoveOverlay(v.marketProfile)
+	}
+	if kind != ProfileNone && v.minutes == nil {
+		v.minutes = newMinuteCache(v.source)
+	}
+	switch kind {
+	case ProfileVolume:
+		if v.volumeProfile == nil {
+			v.volumeProfile = NewVolumeProfile(v.config, v.layout, v.minutes)
+		}
+		v.chart.AddOverlay(v.volumeProfile)
+	case ProfileTPO:
+		if v.marketProfile == nil {
+			v.marketProfile = NewMarketProfile(v.config, v.layout, v.minutes)
+		}
+		v.chart.AddOverlay(v.marketProfile)
+	}
+	v.profile = kind
+	v.needsRedraw = true
+}
+
+// setVolumePlacement shows the volume in its own pane, over the price pane
+// or not at all
+func (v *ChartView) setVolumePlacement(placement VolumePlacement) {
+	pane := v.chart.panes.Find(v.volume)
+	if pane != nil && placement != VolumeInPane {
+		v.chart.panes.Remove(pane)
+	} else if pane == nil && placement == VolumeInPane {
+		v.chart.panes.Add(v.volume, 1)
+	}
+	v.volumePlacement = placement
+	if placement == VolumeOverlay {
+		v.volume.fitOverlay(v.chart)
+	}
+	v.needsRedraw = true
+}
+
+// viewport identifies the horizontal view, for detecting scroll and zoom
+type viewport struct {
+	from   int64
+	scroll float64
+	zoom   float64
+}
+
+func (v *ChartView) viewport() viewport {
+	return viewport{from: v.chart.ts_from, scroll: v.chart.scroll, zoom: v.chart.Zoom}
+}
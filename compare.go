@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"golang.org/x/image/font"
+)
+
+// CompareMode selects how a compare series is placed against the main series
+type CompareMode int
+
+const (
+	// ComparePercent rebases the series onto the main close at the first
+	// visible bar, so both read as percent change on a percent scale
+	ComparePercent CompareMode = iota
+	// CompareSecondaryAxis fits the series to its own scale, labelled along
+	// the right edge of the price pane
+	CompareSecondaryAxis
+	compareModeCount
+)
+
+func (m CompareMode) String() string {
+	if m == CompareSecondaryAxis {
+		return "Secondary axis"
+	}
+	return "Percent"
+}
+
+// Compare is another symbol's close line drawn over the price pane. Its bars
+// come from the symbol's own source and are matched to the chart by bar time.
+type Compare struct {
+	Symbol    string
+	Color     color.RGBA
+	Mode      CompareMode
+	timeframe *Timeframe
+	closes    map[int64]float64 // Close by bar time
+	chart     *Chart
+	fonts     *fontCache
+	config    ChartConfig
+	layout    *Layout
+}
+
+func NewCompare(config ChartConfig, layout *Layout, chart *Chart, symbol string, source MinuteSource, clr color.RGBA) *Compare {
+	timeframe := NewTimeframe(source, time.Duration(chart.barInterval())*time.Millisecond)
+	timeframe.Session = config.session()
+	return &Compare{
+		Symbol:    symbol,
+		Color:     clr,
+		timeframe: timeframe,
+		closes:    make(map[int64]float64),
+		chart:     chart,
+		fonts:     newFontCache(11),
+		config:    config,
+		layout:    layout,
+	}
+}
+
+// Load reads the compare bars at the chart's interval
+func (c *Compare) Load() error {
+	c.timeframe.Interval = time.Duration(c.chart.barInterval()) * time.Millisecond
+	if data := c.chart.Data; len(data) > 0 {
+		// Cover the chart's bars, which may be a fixed range
+		c.timeframe.From = data[0].Time
+		c.timeframe.To = data[len(data)-1].Time + c.chart.barInterval()
+	}
+	bars, err := c.timeframe.GetBars()
+	if err != nil {
+		return fmt.Errorf("load %s: %w", c.Symbol, err)
+	}
+	closes := make(map[int64]float64, len(bars))
+	for _, b := range bars {
+		closes[b.Time] = b.Close
+	}
+	c.closes = closes
+	c.chart.overlaysRev++
+	return nil
+}
+
+// factor returns the multiplier that rebases the compare closes onto the
+// main series at the first visible bar both have
+func (c *Compare) factor(start, end int) (float64, bool) {
+	for i := start; i < end; i++ {
+		if v, ok := c.closes[c.chart.Data[i].Time]; ok && v != 0 {
+			return c.chart.Data[i].Close / v, true
+		}
+	}
+	return 0, false
+}
+
+// extent returns the compare closes' range over the bars start..end-1
+func (c *Compare) extent(start, end int) (min, max float64, ok bool) {
+	min, max = math.Inf(1), math.Inf(-1)
+	for i := start; i < end; i++ {
+		if v, found := c.closes[c.chart.Data[i].Time]; found {
+			min, max = math.Min(min, v), math.Max(max, v)
+			ok = true
+		}
+	}
+	return min, max, ok
+}
+
+// PriceRange reports the rebased line's extent so percent-mode compares take
+// part in auto-fit; a secondary axis has its own range
+func (c *Compare) PriceRange(from, to int64) (min, max float64, ok bool) {
+	if c.Mode != ComparePercent {
+		return 0, 0, false
+	}
+	start, end := c.chart.barAt(from), c.chart.barAt(to)+1
+	if start < 0 || end <= start {
+		return 0, 0, false
+	}
+	f, ok := c.factor(start, end)
+	if !ok {
+		return 0, 0, false
+	}
+	min, max, ok = c.extent(start, end)
+	return min * f, max * f, ok
+}
+
+// Change returns the percent change of the compare over the visible bars
+func (c *Compare) Change() (float64, bool) {
+	start, end := c.chart.visibleRange()
+	if start == -1 {
+		return 0, false
+	}
+	first, last := math.NaN(), math.NaN()
+	for i := start; i < end; i++ {
+		if v, ok := c.closes[c.chart.Data[i].Time]; ok {
+			if math.IsNaN(first) {
+				first = v
+			}
+			last = v
+		}
+	}
+	if math.IsNaN(first) || first == 0 {
+		return 0, false
+	}
+	return (last/first - 1) * 100, true
+}
+
+func (c *Compare) Draw(screen Renderer, chart *Chart) {
+	start, end := chart.visibleRange()
+	if start == -1 {
+		return
+	}
+
+	// toY maps a compare close to the pane for the current mode
+	var toY func(v float64) float32
+	var axisMin, axisMax float64
+	switch c.Mode {
+	case CompareSecondaryAxis:
+		min, max, ok := c.extent(start, end)
+		if !ok {
+			return
+		}
+		padding := (max - min) * c.config.PricePadding
+		if padding == 0 {
+			padding = math.Max(math.Abs(max)*0.01, 1e-8)
+		}
+		axisMin, axisMax = min-padding, max+padding
+		pane := chart.panes.Main()
+		toY = func(v float64) float32 {
+			frac := (v - axisMin) / (axisMax - axisMin)
+			if chart.Scale.Inverted {
+				frac = 1 - frac
+			}
+			return float32(pane.Bottom - frac*pane.Height())
+		}
+	default:
+		f, ok := c.factor(start, end)
+		if !ok {
+			return
+		}
+		toY = func(v float64) float32 { return chart.priceToY(v * f) }
+	}
+
+	// Gaps in the compare data break the line
+	var path Path
+	drawing := false
+	for i := start; i < end; i++ {
+		v, ok := c.closes[chart.Data[i].Time]
+		if !ok {
+			drawing = false
+			continue
+		}
+		x, y := chart.barX(i-start), toY(v)
+		if drawing {
+			path.LineTo(x, y)
+		} else {
+			path.MoveTo(x, y)
+			drawing = true
+		}
+	}
+	screen.StrokePath(&path, c.layout.LineWidth, c.Color)
+
+	if c.Mode == CompareSecondaryAxis {
+		c.drawAxis(screen, toY, axisMin, axisMax)
+	}
+}
+
+// drawAxis labels the secondary scale along the right edge of the price pane
+func (c *Compare) drawAxis(screen Renderer, toY func(float64) float32, lo, hi float64) {
+	face := c.fonts.get(c.layout)
+	pane := c.chart.panes.Main()
+	count := int(pane.Height() / (float64(c.fonts.height) * c.config.MinLabelSpacing))
+	step := calculateStep(hi-lo, max(count, 2))
+	right := int(c.layout.Width-c.layout.RightMargin) - int(c.layout.Px(4))
+	for v := math.Ceil(lo/step) * step; v <= hi; v += step {
+		label := formatPriceLabel(v, step, 0)
+		width := font.MeasureString(face, label).Ceil()
+		y := int(toY(v)) + c.fonts.height/2
+		screen.Text(label, face, right-width, y, c.Color)
+	}
+}
+
+// cycleCompareMode switches every compare to the next mode. Percent compares
+// put the chart on a percent scale so the lines read as change.
+func (c *Chart) cycleCompareMode() {
+	var mode CompareMode
+	found := false
+	for _, o := range c.overlays {
+		if cmp, ok := o.(*Compare); ok {
+			cmp.Mode = (cmp.Mode + 1) % compareModeCount
+			c.overlaysRev++
+			mode, found = cmp.Mode, true
+		}
+	}
+	if !found {
+		return
+	}
+	if mode == ComparePercent {
+		c.Scale.Mode = ScalePercent
+	} else if c.Scale.Mode == ScalePercent {
+		c.Scale.Mode = ScaleLinear
+	}
+	c.fitPriceRange()
+}
+
+// drawLegend lists the chart's symbol and its compare series with their
+// change over the visible bars in the top-left of the price pane
+func (v *ChartView) drawLegend(screen Renderer) {
+	var compares []*Compare
+	for _, o := range v.chart.overlays {
+		if cmp, ok := o.(*Compare); ok {
+			compares = append(compares, cmp)
+		}
+	}
+	if len(compares) == 0 {
+		return
+	}
+
+	face := v.legendFonts.get(v.layout)
+	lineHeight := v.legendFonts.height + int(v.layout.Px(4))
+	swatch := float32(v.layout.Px(10))
+	x := int(v.layout.LeftMargin + v.layout.Px(8))
+	y := int(v.chart.panes.Main().Top + v.layout.Px(6))
+
+	entry := func(label string, clr color.RGBA) {
+		screen.FillRect(float32(x), float32(y)+float32(lineHeight)/2-swatch/2, swatch, swatch, clr)
+		screen.Text(label, face, x+int(swatch)+int(v.layout.Px(6)), y+v.legendFonts.height, v.config.LabelColor)
+		y += lineHeight
+	}
+
+	label := v.spec.Symbol
+	if start, end := v.chart.visibleRange(); start != -1 && v.chart.Data[start].Close != 0 {
+		change := (v.chart.Data[end-1].Close/v.chart.Data[start].Close - 1) * 100
+		label = fmt.Sprintf("%s %+.2f%%", label, change)
+	}
+	entry(label, v.config.LineColor)
+	for _, cmp := range compares {
+		label := cmp.Symbol
+		if change, ok := cmp.Change(); ok {
+			label = fmt.Sprintf("%s %+.2f%%", label, change)
+		}
+		entry(label, cmp.Color)
+	}
+}
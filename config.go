@@ -2,29 +2,57 @@ package main
 
 import (
 	"image/color"
+	"time"
 )
 
 type ChartConfig struct {
-	// Colors
+	// Theme
+	Theme    string // Name of the theme the colours come from
+	ThemeDir string // Directory of JSON theme files loaded at startup
+
+	// Keyboard
+	KeysFile string // JSON file remapping key bindings, loaded at startup
+
+	// Export
+	ExportDir string // Directory selected bars are exported to
+
+	// Colors, set by Theme.Apply
 	BackgroundColor color.RGBA
 	AxisColor       color.RGBA
 	GridColor       color.RGBA
 	LabelColor      color.RGBA
-	// Grid colors
-	PrimaryGridColor   color.RGBA // Vertical grid
-	SecondaryGridColor color.RGBA // Horizontal grid
+	// Time segment colors, alternating between time ticks
+	PrimaryGridColor   color.RGBA
+	SecondaryGridColor color.RGBA
 
-	// Bar colors
-	BarColor   color.RGBA
-	OpenColor  color.RGBA
-	CloseColor color.RGBA
+	// Series colors
+	UpColor           color.RGBA
+	DownColor         color.RGBA
+	UpWickColor       color.RGBA
+	DownWickColor     color.RGBA
+	LineColor         color.RGBA
+	AreaFillColor     color.RGBA // Premultiplied, drawn under the area line
+	BaselineUpColor   color.RGBA
+	BaselineDownColor color.RGBA
+	BaselineUpFill    color.RGBA // Premultiplied
+	BaselineDownFill  color.RGBA // Premultiplied
 
 	VolumeUpColor   color.RGBA
 	VolumeDownColor color.RGBA
+	VolumeMAColor   color.RGBA
+
+	CompareColors []color.RGBA // Compare series take these in turn
+
+	ProfileColor           color.RGBA // Premultiplied
+	ProfileValueAreaColor  color.RGBA // Premultiplied
+	ProfilePOCColor        color.RGBA
+	TPOInitialBalanceColor color.RGBA
+	TPOSingleColor         color.RGBA
 
 	CrosshairColor       color.RGBA
 	CrosshairTextColor   color.RGBA
 	CrosshairBgColor     color.RGBA
+	SelectionColor       color.RGBA // Premultiplied
 	FrameTimeMABgColor   color.RGBA
 	FrameTimeMATextColor color.RGBA
 
@@ -37,62 +65,146 @@ type ChartConfig struct {
 	BottomMargin float64
 
 	// Spacing
-	MinLabelSpacing float64 // Multiple of text height
-	MinPriceLabels  int
+	MinLabelSpacing     float64 // Multiple of text height
+	MinPriceLabels      int
+	MinTimeLabelSpacing float64 // Pixels between time labels
+
+	// Symbol
+	TickSize float64 // Price increment until a chart's bars give one, caps label precision
+
+	// Price range fitting
+	PricePadding float64 // Fraction of the visible range added above and below
+	FitOverlays  bool    // Include overlays when fitting the price range
+
+	// Scrolling
+	KineticScrolling bool    // Keep scrolling after a drag is released
+	KineticFriction  float64 // Fraction of the glide speed lost each second
+
+	// Touch
+	TouchSlop float64       // Pixels a finger moves before it pans
+	LongPress time.Duration // Hold that pins the crosshair under a finger
 
 	// Bar configuration
+	SeriesStyle   SeriesStyle
 	BarWidth      float64
 	BarSpacing    float64 // New spacing between bars
+	BodySpacing   float64 // Gap between neighbouring candle bodies
 	VolumeSpacing float64
+	// How volumes combine when zoomed out past a bar per pixel
+	VolumeDecimation VolumeDecimation
+	LineWidth        float32
+	BaselinePrice    float64 // Reference for StyleBaseline, 0 uses the first visible close
+
+	// Volume display
+	VolumePlacement     VolumePlacement
+	VolumeQuote         bool    // Volume in the quote currency, base volume × typical price
+	VolumeMAPeriod      int     // Bars in the volume moving average, 0 hides it
+	VolumeOverlayHeight float64 // Share of the price pane volume takes as an overlay
+
+	// Trading day for daily bars and market profiles, starting SessionStart
+	// after midnight in SessionTimezone
+	SessionTimezone string
+	SessionStart    time.Duration
+
+	// Market profile over the price pane
+	Profile                ProfileKind
+	VolumeProfileRows      int     // Price rows of the volume profile
+	VolumeProfileBucket    float64 // Price per row, overrides the row count when set
+	VolumeProfileValueArea float64 // Share of the volume inside the value area
+	VolumeProfileWidth     float64 // Share of the pane width the longest row takes
+	TPORows                int     // Rough row count of the TPO profile over the visible prices
+	TPORowSize             float64 // Price per TPO row, overrides the row count when set
 
 	// Appearance
-	AxisWidth float32
-	GridWidth float32
+	ShowRightAxis bool // Price axis with last-price and high/low markers in the right margin
+	AxisWidth     float32
+	GridWidth     float32
 }
 
 var DefaultConfig = ChartConfig{
-	BackgroundColor:      color.RGBA{R: 10, G: 10, B: 10, A: 255},
-	AxisColor:            color.RGBA{R: 100, G: 100, B: 100, A: 255},
-	GridColor:            color.RGBA{R: 50, G: 50, B: 50, A: 255},    // Pure black
-	LabelColor:           color.RGBA{R: 255, G: 255, B: 255, A: 255}, // Pure white
-	PrimaryGridColor:     color.RGBA{R: 15, G: 15, B: 15, A: 255},    // Darker vertical grid
-	SecondaryGridColor:   color.RGBA{R: 35, G: 35, B: 35, A: 255},    // Darker horizontal grid
-	BarColor:             color.RGBA{R: 255, G: 255, B: 255, A: 255},
-	OpenColor:            color.RGBA{R: 255, G: 255, B: 255, A: 255},
-	CloseColor:           color.RGBA{R: 255, G: 255, B: 255, A: 255},
-	VolumeUpColor:        color.RGBA{R: 0, G: 100, B: 0, A: 255},
-	VolumeDownColor:      color.RGBA{R: 100, G: 0, B: 0, A: 255},
-	CrosshairColor:       color.RGBA{R: 150, G: 150, B: 150, A: 255},
-	CrosshairTextColor:   color.RGBA{R: 200, G: 200, B: 200, A: 255},
-	CrosshairBgColor:     color.RGBA{R: 30, G: 30, B: 30, A: 255},
-	FrameTimeMATextColor: color.RGBA{R: 100, G: 100, B: 100, A: 255},
-	FrameTimeMABgColor:   color.RGBA{R: 20, G: 20, B: 20, A: 255},
-	BarWidth:             1.0,
-	BarSpacing:           5.0, // Space between bars
-	VolumeSpacing:        2.0,
+	Theme:    "dark",
+	ThemeDir: "themes",
+
+	KeysFile: "keys.json",
+
+	ExportDir: ".",
+
+	SeriesStyle:   StyleCandles,
+	BarWidth:      1.0,
+	BarSpacing:    5.0, // Space between bars
+	BodySpacing:   2.0,
+	VolumeSpacing: 2.0,
+	LineWidth:     1.5,
+
+	VolumePlacement:     VolumeInPane,
+	VolumeMAPeriod:      20,
+	VolumeOverlayHeight: 0.25,
+
+	SessionTimezone: "UTC",
+
+	VolumeProfileRows:      24,
+	VolumeProfileValueArea: 0.7,
+	VolumeProfileWidth:     0.25,
+	TPORows:                40,
 
 	Width:        1000,
 	Height:       700,
 	LeftMargin:   80,
-	RightMargin:  50,
+	RightMargin:  90,
 	TopMargin:    30,
 	BottomMargin: 50,
 
 	MinLabelSpacing: 4, // 4x text height
 	MinPriceLabels:  5,
 
-	AxisWidth: 1.0,
-	GridWidth: 1.2,
+	MinTimeLabelSpacing: 90,
+
+	TickSize: 0.01,
+
+	PricePadding: 0.05,
+	FitOverlays:  true,
+
+	KineticScrolling: true,
+	KineticFriction:  0.95,
+
+	TouchSlop: 8,
+	LongPress: 500 * time.Millisecond,
+
+	ShowRightAxis: true,
+	AxisWidth:     1.0,
+	GridWidth:     1.2,
+}
+
+// DefaultCharts lists the symbol and timeframe of each chart in the grid,
+// in the order the grid fills
+var DefaultCharts = []ChartSpec{
+	{Symbol: "BTCUSDT", Interval: 15 * time.Minute},
+	{Symbol: "BTCUSDT", Interval: time.Hour, Compare: []string{"ETHUSDT", "SOLUSDT"}},
+	{Symbol: "ETHUSDT", Interval: 15 * time.Minute},
+	{Symbol: "ETHUSDT", Interval: time.Hour},
+	{Symbol: "SOLUSDT", Interval: 15 * time.Minute},
+	{Symbol: "BNBUSDT", Interval: time.Hour},
+}
+
+// DefaultSynthetics are instruments computed from stored symbols. A chart or
+// compare symbol may name one of these or be an expression itself.
+var DefaultSynthetics = []SyntheticDef{
+	{Name: "ETHBTC", Expr: "ETHUSDT/BTCUSDT"},
+	{Name: "BTCETHAVG", Expr: "(BTCUSDT+ETHUSDT)/2"},
 }
 
 type TimeFormatConfig struct {
-	MonthlyFormat string
-	DailyFormat   string
-	DefaultFormat string
+	YearlyFormat   string // Time axis label on the first tick of a year
+	MonthlyFormat  string // Time axis label on the first tick of a month
+	DailyFormat    string // Time axis label at midnight
+	IntradayFormat string // Time axis label within a day
+	DefaultFormat  string // Crosshair readout
 }
 
 var TimeFormat = TimeFormatConfig{
-	MonthlyFormat: "Jan 2",
-	DailyFormat:   "2",
-	DefaultFormat: "Jan 2 15:04",
+	YearlyFormat:   "2006",
+	MonthlyFormat:  "Jan",
+	DailyFormat:    "2",
+	IntradayFormat: "15:04",
+	DefaultFormat:  "Mon 2 Jan 2006 15:04",
 }
@@ -9,14 +9,13 @@ import (
 	"time"
 
 	"github.com/akrylysov/pogreb"
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/text"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/gofont/goregular"
 	"golang.org/x/image/font/opentype"
 )
 
 type Database struct {
+	symbol         string
 	db             *pogreb.DB
 	errorMsg       string // Persistent error message
 	fetchStatus    string // Status of ongoing fetch operations
@@ -28,13 +27,13 @@ type Database struct {
 	fetchedMinutes int64 // Minutes already fetched
 }
 
-func loadFont() font.Face {
+func loadFont(size float64) font.Face {
 	tt, err := opentype.Parse(goregular.TTF)
 	if err != nil {
 		panic(fmt.Errorf("failed to parse font: %v", err))
 	}
 	face, err := opentype.NewFace(tt, &opentype.FaceOptions{
-		Size:    12,
+		Size:    size,
 		DPI:     72,
 		Hinting: font.HintingFull,
 	})
@@ -44,15 +43,12 @@ func loadFont() font.Face {
 	return face
 }
 
-func NewDatabase() (*Database, error) {
-	db, err := pogreb.Open("BTCUSDT.db", nil)
+// NewDatabase opens the 1-minute store for symbol, kept in <symbol>.db, and
+// starts bringing it up to date in the background
+func NewDatabase(symbol string) (*Database, error) {
+	d, err := openDatabase(symbol)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %v", err)
-	}
-
-	d := &Database{
-		db:       db,
-		fontFace: loadFont(),
+		return nil, err
 	}
 
 	// Start fetching data asynchronously
@@ -61,15 +57,44 @@ func NewDatabase() (*Database, error) {
 	return d, nil
 }
 
+// openDatabase opens the store for symbol without fetching anything
+func openDatabase(symbol string) (*Database, error) {
+	db, err := pogreb.Open(symbol+".db", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	return &Database{
+		symbol:   symbol,
+		db:       db,
+		fontFace: loadFont(12),
+	}, nil
+}
+
+// Minute reads the stored 1-minute bar opening at t
+func (d *Database) Minute(t int64) (OHLCV, bool, error) {
+	value, err := d.db.Get(int64ToBytes(t))
+	if err != nil {
+		return OHLCV{}, false, fmt.Errorf("failed to read from db: %v", err)
+	}
+	if value == nil {
+		return OHLCV{}, false, nil
+	}
+	ohlcv, err := deserializeOHLCV(value)
+	if err != nil {
+		return OHLCV{}, false, fmt.Errorf("failed to deserialize OHLCV: %v", err)
+	}
+	return ohlcv, true, nil
+}
+
 func (d *Database) Close() error {
 	return d.db.Close()
 }
 
-func (d *Database) DrawError(screen *ebiten.Image) {
+func (d *Database) DrawError(screen Renderer) {
 	if d.errorMsg == "" {
 		return
 	}
-	text.Draw(screen, d.errorMsg, d.fontFace, 10, 20, color.RGBA{255, 0, 0, 255})
+	screen.Text(d.errorMsg, d.fontFace, 10, 20, color.RGBA{255, 0, 0, 255})
 }
 
 func (d *Database) IsEmpty() (bool, error) {
@@ -81,6 +106,47 @@ func (d *Database) IsEmpty() (bool, error) {
 	return false, err
 }
 
+// Earliest returns the open time of the oldest stored minute, kept as a key
+// as minutes are written. ok is false for an empty store, or one written
+// before the key was kept until its next fetch records it.
+func (d *Database) Earliest() (int64, bool, error) {
+	value, err := d.db.Get([]byte("earliest_timestamp"))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get earliest timestamp: %v", err)
+	}
+	if value == nil {
+		return 0, false, nil
+	}
+	return bytesToInt64(value), true, nil
+}
+
+func (d *Database) setEarliestTimestamp(timestamp int64) error {
+	return d.db.Put([]byte("earliest_timestamp"), int64ToBytes(timestamp))
+}
+
+// scanEarliest finds the oldest stored minute by visiting every key, for
+// stores written before the earliest timestamp was kept. It returns 0 for
+// an empty store.
+func (d *Database) scanEarliest() (int64, error) {
+	var earliest int64
+	it := d.db.Items()
+	for {
+		key, _, err := it.Next()
+		if err == pogreb.ErrIterationDone {
+			return earliest, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to scan db: %v", err)
+		}
+		if len(key) != 8 {
+			continue // The latest and earliest timestamp entries
+		}
+		if t := bytesToInt64(key); earliest == 0 || t < earliest {
+			earliest = t
+		}
+	}
+}
+
 func (d *Database) getLatestTimestamp() (int64, error) {
 	latestKey := []byte("latest_timestamp")
 	value, err := d.db.Get(latestKey)
@@ -103,8 +169,14 @@ func bytesToInt64(b []byte) int64 {
 	return int64(binary.BigEndian.Uint64(b))
 }
 
+// ensureLastData fetches the minutes since the latest stored one. It returns
+// at once when a fetch into the store is already running.
 func (d *Database) ensureLastData() error {
 	d.fetchMutex.Lock()
+	if d.fetching {
+		d.fetchMutex.Unlock()
+		return nil
+	}
 	d.fetching = true
 	d.fetchStart = time.Now()
 	d.fetchStatus = "Fetching data via API: https://api.binance.com/api/v3/klines..."
@@ -124,6 +196,24 @@ func (d *Database) ensureLastData() error {
 		return err
 	}
 
+	// Record the oldest minute of stores written before it was kept
+	earliest, haveEarliest, err := d.Earliest()
+	if err != nil {
+		d.setError(err)
+		return err
+	}
+	if !empty && !haveEarliest {
+		if earliest, err = d.scanEarliest(); err != nil {
+			d.setError(err)
+			return err
+		}
+		if err := d.setEarliestTimestamp(earliest); err != nil {
+			d.setError(fmt.Errorf("failed to update earliest timestamp: %v", err))
+			return err
+		}
+		haveEarliest = true
+	}
+
 	now := time.Now().UTC()
 	endTime := now.Unix() * 1000
 	var startTime int64
@@ -158,7 +248,7 @@ func (d *Database) ensureLastData() error {
 	currentEndTime := endTime
 	for d.totalMinutes > 0 {
 		fetchMinutes := int64(min(int(d.totalMinutes), maxLimit))
-		data, err := Fetch(fetchMinutes, currentEndTime)
+		data, err := Fetch(d.symbol, fetchMinutes, currentEndTime)
 		if err != nil {
 			d.setError(fmt.Errorf("failed to fetch data ending at %d: %v", currentEndTime, err))
 			return err
@@ -195,7 +285,7 @@ func (d *Database) ensureLastData() error {
 
 	// Store data in the database, excluding current incomplete minute
 	currentMinute := time.Now().UTC().Unix() / 60 * 60 * 1000
-	var latestTimestamp int64
+	var latestTimestamp, earliestTimestamp int64
 	for _, ohlcv := range allData {
 		if ohlcv.Time >= currentMinute {
 			continue // Skip current incomplete minute
@@ -213,6 +303,17 @@ func (d *Database) ensureLastData() error {
 		if ohlcv.Time > latestTimestamp {
 			latestTimestamp = ohlcv.Time
 		}
+		if earliestTimestamp == 0 || ohlcv.Time < earliestTimestamp {
+			earliestTimestamp = ohlcv.Time
+		}
+	}
+
+	// Update earliest timestamp
+	if earliestTimestamp != 0 && (!haveEarliest || earliestTimestamp < earliest) {
+		if err := d.setEarliestTimestamp(earliestTimestamp); err != nil {
+			d.setError(fmt.Errorf("failed to update earliest timestamp: %v", err))
+			return err
+		}
 	}
 
 	// Update latest timestamp
@@ -13,9 +13,9 @@ import (
 // BinanceKline represents the structure of a Binance API kline/candlestick response
 type BinanceKline []interface{}
 
-// Fetch retrieves OHLCV data from Binance API
-func Fetch(num, totime int64) ([]OHLCV, error) {
-	url := "https://api.binance.com/api/v3/klines?symbol=BTCUSDT&interval=1m"
+// Fetch retrieves 1-minute OHLCV data for symbol from Binance API
+func Fetch(symbol string, num, totime int64) ([]OHLCV, error) {
+	url := "https://api.binance.com/api/v3/klines?symbol=" + symbol + "&interval=1m"
 	req_url := url + "&limit=" + strconv.FormatInt(num, 10) + "&endTime=" + strconv.FormatInt(totime, 10)
 
 	// Log the request time in both UTC and local time for debugging
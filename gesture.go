@@ -0,0 +1,126 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// touchPoint is one finger on the screen, in view coordinates
+type touchPoint struct {
+	id   ebiten.TouchID
+	x, y float64
+}
+
+// gesture is what the fingers did since the previous update
+type gesture struct {
+	began    bool    // Fingers went down with none down before
+	panX     float64 // Horizontal movement of the fingers' centre
+	zoom     float64 // Change in the spread of two fingers, 1 for none
+	x, y     float64 // Centre of the fingers
+	hold     bool    // A finger is held down after a long press, at x, y
+	tap      bool    // A quick touch was lifted without moving
+	released bool    // The last finger was lifted after panning
+}
+
+// gestureRecognizer turns touch positions, sampled once per update, into
+// pans, pinches, long presses and taps. It reads nothing but its input, so
+// any sequence of touches can be played through it.
+type gestureRecognizer struct {
+	LongPress time.Duration // Hold before a still finger counts as a long press
+	Slop      float64       // Distance a finger moves before it pans
+	prev      []touchPoint
+	downAt    time.Time
+	downX     float64
+	downY     float64
+	most      int  // Most fingers down at once since the first went down
+	moved     bool // Panned or pinched since the first finger went down
+	holding   bool // Long pressed since the first finger went down
+}
+
+// Update takes the fingers down at now and returns the gesture since the
+// previous update. Movement is only measured while the same fingers stay
+// down, so adding or lifting one doesn't make the view jump.
+func (r *gestureRecognizer) Update(now time.Time, touches []touchPoint) gesture {
+	g := gesture{zoom: 1}
+	if len(touches) == 0 {
+		if len(r.prev) > 0 {
+			g.x, g.y = touchCentre(r.prev)
+			g.tap = r.most == 1 && !r.moved && !r.holding
+			g.released = r.moved && !r.holding
+		}
+		r.prev = r.prev[:0]
+		return g
+	}
+
+	g.x, g.y = touchCentre(touches)
+	if len(r.prev) == 0 {
+		g.began = true
+		r.downAt, r.downX, r.downY = now, g.x, g.y
+		r.most, r.moved, r.holding = 0, false, false
+	}
+	r.most = max(r.most, len(touches))
+
+	if sameTouches(r.prev, touches) {
+		prevX, _ := touchCentre(r.prev)
+		switch {
+		case r.holding:
+			g.hold = true
+		case len(touches) == 2:
+			r.moved = true
+			g.panX = g.x - prevX
+			if spread := touchSpread(r.prev); spread > 0 {
+				g.zoom = touchSpread(touches) / spread
+			}
+		case len(touches) > 2:
+			// More fingers than a pinch are ignored
+		case r.moved || math.Hypot(g.x-r.downX, g.y-r.downY) > r.Slop:
+			r.moved = true
+			g.panX = g.x - prevX
+		case r.most == 1 && now.Sub(r.downAt) >= r.LongPress:
+			r.holding = true
+			g.hold = true
+		}
+	}
+	r.prev = append(r.prev[:0], touches...)
+	return g
+}
+
+// sameTouches reports whether a and b are the same fingers
+func sameTouches(a, b []touchPoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].id != b[i].id {
+			return false
+		}
+	}
+	return true
+}
+
+// touchCentre returns the mean position of the fingers
+func touchCentre(touches []touchPoint) (x, y float64) {
+	for _, t := range touches {
+		x += t.x
+		y += t.y
+	}
+	n := float64(len(touches))
+	return x / n, y / n
+}
+
+// touchSpread returns the distance between the first two fingers
+func touchSpread(touches []touchPoint) float64 {
+	return math.Hypot(touches[1].x-touches[0].x, touches[1].y-touches[0].y)
+}
+
+// appendTouches appends the fingers down on the screen to touches, in view
+// coordinates of layout
+func appendTouches(touches []touchPoint, ids []ebiten.TouchID, layout *Layout) []touchPoint {
+	for _, id := range ids {
+		x, y := ebiten.TouchPosition(id)
+		touches = append(touches, touchPoint{id, float64(x) - layout.OriginX, float64(y) - layout.OriginY})
+	}
+	return touches
+}
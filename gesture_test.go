@@ -0,0 +1,136 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// gestureStep is the fingers down at ms milliseconds into a sequence
+type gestureStep struct {
+	ms      int
+	touches []touchPoint
+}
+
+// playGestures feeds steps to a recognizer with an 8 pixel slop and a
+// 500ms long press, returning the gesture of each step
+func playGestures(steps []gestureStep) []gesture {
+	r := gestureRecognizer{LongPress: 500 * time.Millisecond, Slop: 8}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var gestures []gesture
+	for _, s := range steps {
+		gestures = append(gestures, r.Update(start.Add(time.Duration(s.ms)*time.Millisecond), s.touches))
+	}
+	return gestures
+}
+
+// finger returns finger id at x, y
+func finger(id ebiten.TouchID, x, y float64) touchPoint {
+	return touchPoint{id: id, x: x, y: y}
+}
+
+func TestGestureTap(t *testing.T) {
+	g := playGestures([]gestureStep{
+		{0, []touchPoint{finger(1, 100, 100)}},
+		{50, []touchPoint{finger(1, 102, 101)}},
+		{100, nil},
+	})
+	if !g[0].began {
+		t.Errorf("first touch did not begin a gesture")
+	}
+	if g[1].panX != 0 {
+		t.Errorf("movement within the slop panned %v", g[1].panX)
+	}
+	if !g[2].tap || g[2].released {
+		t.Errorf("lift after a still touch: tap %v, released %v, want a tap", g[2].tap, g[2].released)
+	}
+}
+
+func TestGesturePanAfterSlop(t *testing.T) {
+	g := playGestures([]gestureStep{
+		{0, []touchPoint{finger(1, 100, 100)}},
+		{16, []touchPoint{finger(1, 105, 100)}},
+		{32, []touchPoint{finger(1, 120, 100)}},
+		{48, []touchPoint{finger(1, 130, 100)}},
+		{64, nil},
+	})
+	want := []float64{0, 0, 15, 10}
+	for i, panX := range want {
+		if g[i].panX != panX {
+			t.Errorf("step %d panned %v, want %v", i, g[i].panX, panX)
+		}
+	}
+	if !g[4].released || g[4].tap {
+		t.Errorf("lift after panning: released %v, tap %v, want released", g[4].released, g[4].tap)
+	}
+}
+
+func TestGesturePinch(t *testing.T) {
+	g := playGestures([]gestureStep{
+		{0, []touchPoint{finger(1, 100, 100), finger(2, 200, 100)}},
+		{16, []touchPoint{finger(1, 90, 100), finger(2, 230, 100)}},
+		{32, nil},
+	})
+	if g[1].x != 160 || g[1].y != 100 {
+		t.Errorf("pinch centre %v, %v, want 160, 100", g[1].x, g[1].y)
+	}
+	if g[1].panX != 10 {
+		t.Errorf("pinch panned %v, want 10", g[1].panX)
+	}
+	if math.Abs(g[1].zoom-1.4) > 1e-9 {
+		t.Errorf("pinch zoomed %v, want 1.4", g[1].zoom)
+	}
+	if g[2].tap {
+		t.Errorf("lifting a pinch counted as a tap")
+	}
+}
+
+func TestGestureLongPress(t *testing.T) {
+	g := playGestures([]gestureStep{
+		{0, []touchPoint{finger(1, 100, 100)}},
+		{300, []touchPoint{finger(1, 101, 100)}},
+		{600, []touchPoint{finger(1, 101, 100)}},
+		{700, []touchPoint{finger(1, 150, 100)}},
+		{800, nil},
+	})
+	if g[1].hold {
+		t.Errorf("held before the long press delay")
+	}
+	if !g[2].hold || g[2].x != 101 {
+		t.Errorf("long press: hold %v at %v, want a hold at 101", g[2].hold, g[2].x)
+	}
+	if !g[3].hold || g[3].panX != 0 || g[3].x != 150 {
+		t.Errorf("moving after a long press: hold %v at %v panning %v, want a hold at 150 without panning", g[3].hold, g[3].x, g[3].panX)
+	}
+	if g[4].tap || g[4].released {
+		t.Errorf("lift after a hold: tap %v, released %v, want neither", g[4].tap, g[4].released)
+	}
+}
+
+func TestGestureFingerChangeDoesNotJump(t *testing.T) {
+	g := playGestures([]gestureStep{
+		{0, []touchPoint{finger(1, 100, 100)}},
+		{16, []touchPoint{finger(1, 120, 100)}},
+		{32, []touchPoint{finger(1, 120, 100), finger(2, 300, 100)}},
+		{48, []touchPoint{finger(1, 125, 100), finger(2, 305, 100)}},
+		{64, []touchPoint{finger(2, 305, 100)}},
+		{80, []touchPoint{finger(2, 310, 100)}},
+	})
+	if g[2].panX != 0 || g[2].zoom != 1 {
+		t.Errorf("adding a finger panned %v and zoomed %v", g[2].panX, g[2].zoom)
+	}
+	if g[3].panX != 5 || g[3].zoom != 1 {
+		t.Errorf("two fingers moving together panned %v and zoomed %v, want 5 and 1", g[3].panX, g[3].zoom)
+	}
+	if g[4].panX != 0 || g[4].zoom != 1 {
+		t.Errorf("lifting a finger panned %v and zoomed %v", g[4].panX, g[4].zoom)
+	}
+	if g[5].panX != 5 {
+		t.Errorf("remaining finger panned %v, want 5", g[5].panX)
+	}
+	if g[2].began || g[4].began {
+		t.Errorf("changing fingers began a new gesture")
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+	rasterx "golang.org/x/image/vector"
+)
+
+// imageRenderer draws to an in-memory RGBA image in pure Go, for writing
+// PNG files without a window or GPU
+type imageRenderer struct {
+	dst *image.RGBA // A sub-image of the whole picture while clipped
+}
+
+func newImageRenderer(width, height int) *imageRenderer {
+	return &imageRenderer{dst: image.NewRGBA(image.Rect(0, 0, width, height))}
+}
+
+// Image returns the rendered picture
+func (r *imageRenderer) Image() *image.RGBA {
+	return r.dst
+}
+
+func (r *imageRenderer) Bounds() image.Rectangle {
+	return r.dst.Bounds()
+}
+
+func (r *imageRenderer) Fill(clr color.Color) {
+	draw.Draw(r.dst, r.dst.Bounds(), image.NewUniform(clr), image.Point{}, draw.Src)
+}
+
+// FillRect fills the pixels whose centres fall inside the rectangle, as
+// Ebiten does without anti-aliasing, but never less than one pixel
+func (r *imageRenderer) FillRect(x, y, width, height float32, clr color.Color) {
+	if width < 0 {
+		x, width = x+width, -width
+	}
+	if height < 0 {
+		y, height = y+height, -height
+	}
+	x0, y0 := int(math.Round(float64(x))), int(math.Round(float64(y)))
+	x1, y1 := int(math.Round(float64(x+width))), int(math.Round(float64(y+height)))
+	if width > 0 && x1 == x0 {
+		x1 = x0 + 1
+	}
+	if height > 0 && y1 == y0 {
+		y1 = y0 + 1
+	}
+	draw.Draw(r.dst, image.Rect(x0, y0, x1, y1).Intersect(r.dst.Bounds()), image.NewUniform(clr), image.Point{}, draw.Over)
+}
+
+func (r *imageRenderer) StrokeLine(x0, y0, x1, y1, width float32, clr color.Color) {
+	switch {
+	case x0 == x1:
+		r.FillRect(x0-width/2, min32(y0, y1), width, max32(y0, y1)-min32(y0, y1), clr)
+	case y0 == y1:
+		r.FillRect(min32(x0, x1), y0-width/2, max32(x0, x1)-min32(x0, x1), width, clr)
+	default:
+		var polys [][]pathPoint
+		polys = appendSegment(polys, pathPoint{x0, y0}, pathPoint{x1, y1}, width)
+		r.fill(polys, clr)
+	}
+}
+
+func (r *imageRenderer) StrokeRect(x, y, width, height, strokeWidth float32, clr color.Color) {
+	r.StrokeLine(x, y, x+width, y, strokeWidth, clr)
+	r.StrokeLine(x, y+height, x+width, y+height, strokeWidth, clr)
+	r.StrokeLine(x, y, x, y+height, strokeWidth, clr)
+	r.StrokeLine(x+width, y, x+width, y+height, strokeWidth, clr)
+}
+
+// StrokePath outlines each segment and rounds the joins between them
+func (r *imageRenderer) StrokePath(path *Path, width float32, clr color.Color) {
+	var polys [][]pathPoint
+	for _, sub := range path.subpaths {
+		for i := 1; i < len(sub); i++ {
+			polys = appendSegment(polys, sub[i-1], sub[i], width)
+			if i+1 < len(sub) {
+				polys = append(polys, circlePolygon(sub[i], width/2))
+			}
+		}
+	}
+	r.fill(polys, clr)
+}
+
+func (r *imageRenderer) FillPath(path *Path, clr color.Color) {
+	r.fill(path.subpaths, clr)
+}
+
+func (r *imageRenderer) DrawBatch(batch *quadBatch) {
+	for _, rect := range batch.rects {
+		r.FillRect(rect.x, rect.y, rect.width, rect.height, rect.clr)
+	}
+}
+
+func (r *imageRenderer) Text(s string, face font.Face, x, y int, clr color.Color) {
+	d := font.Drawer{Dst: r.dst, Src: image.NewUniform(clr), Face: face, Dot: fixed.P(x, y)}
+	d.DrawString(s)
+}
+
+func (r *imageRenderer) Clip(rect image.Rectangle) Renderer {
+	return &imageRenderer{dst: r.dst.SubImage(rect).(*image.RGBA)}
+}
+
+// fill rasterizes the polygons as their union. Each is wound the same way
+// first, since the rasterizer cancels out overlaps of opposite winding.
+func (r *imageRenderer) fill(polys [][]pathPoint, clr color.Color) {
+	b := r.dst.Bounds()
+	if b.Empty() {
+		return
+	}
+	ras := rasterx.NewRasterizer(b.Dx(), b.Dy())
+	ras.DrawOp = draw.Over
+	ox, oy := float32(b.Min.X), float32(b.Min.Y)
+	for _, poly := range polys {
+		if len(poly) < 3 {
+			continue
+		}
+		reversed := signedArea(poly) < 0
+		for k := range poly {
+			p := poly[k]
+			if reversed {
+				p = poly[len(poly)-1-k]
+			}
+			if k == 0 {
+				ras.MoveTo(p.x-ox, p.y-oy)
+			} else {
+				ras.LineTo(p.x-ox, p.y-oy)
+			}
+		}
+		ras.ClosePath()
+	}
+	ras.Draw(r.dst, b, image.NewUniform(clr), image.Point{})
+}
+
+// appendSegment adds the rectangle covering a line of the given width
+// between two points
+func appendSegment(polys [][]pathPoint, a, b pathPoint, width float32) [][]pathPoint {
+	dx, dy := b.x-a.x, b.y-a.y
+	length := float32(math.Hypot(float64(dx), float64(dy)))
+	if length == 0 {
+		return polys
+	}
+	nx, ny := -dy/length*width/2, dx/length*width/2
+	return append(polys, []pathPoint{
+		{a.x + nx, a.y + ny}, {b.x + nx, b.y + ny},
+		{b.x - nx, b.y - ny}, {a.x - nx, a.y - ny},
+	})
+}
+
+// circlePolygon approximates a round join
+func circlePolygon(c pathPoint, radius float32) []pathPoint {
+	const sides = 12
+	poly := make([]pathPoint, sides)
+	for i := range poly {
+		angle := 2 * math.Pi * float64(i) / sides
+		poly[i] = pathPoint{c.x + radius*float32(math.Cos(angle)), c.y + radius*float32(math.Sin(angle))}
+	}
+	return poly
+}
+
+func signedArea(poly []pathPoint) float32 {
+	var area float32
+	for i := range poly {
+		j := (i + 1) % len(poly)
+		area += poly[i].x*poly[j].y - poly[j].x*poly[i].y
+	}
+	return area / 2
+}
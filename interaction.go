@@ -5,60 +5,51 @@ import (
 	"math"
 	"time"
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/text"
-	"github.com/hajimehoshi/ebiten/v2/vector"
 	"golang.org/x/image/font"
-	"golang.org/x/image/font/gofont/goregular"
-	"golang.org/x/image/font/opentype"
 )
 
 type Interaction struct {
 	crosshairX        float64 // Current x position (only changes when snapping)
 	crosshairY        float64 // Current y position (updates freely)
 	snappedBarIdx     int     // Index of bar we're snapped to (-1 if not snapped)
+	fonts             *fontCache
 	fontFace          font.Face
 	labelHeight       int
 	labelPadding      int
 	config            ChartConfig
+	layout            *Layout
 	mousePrice        float64
+	valueText         string // Crosshair readout for the hovered pane
+	hoverPane         *Pane
+	linked            bool // Crosshair follows another chart, so only the time line is shown
 	mouseTime         int64
 	showCrosshair     bool
 	prevShowCrosshair bool // Track previous state of crosshair visibility
 	frameTimes        []float64
 	lastUpdate        time.Time
 	frameTimeMA       float64
+	// Bar the crosshair was stepped to from the keyboard or pinned to by a
+	// long press, 0 follows the cursor. Moving the cursor hands back to it.
+	steppedTime int64
+	stepCursorX int
+	stepCursorY int
 }
 
-func NewInteraction(config ChartConfig) *Interaction {
-	tt, err := opentype.Parse(goregular.TTF)
-	if err != nil {
-		panic(err)
-	}
-
-	face, err := opentype.NewFace(tt, &opentype.FaceOptions{
-		Size:    12,
-		DPI:     72,
-		Hinting: font.HintingFull,
-	})
-	if err != nil {
-		panic(err)
-	}
-
-	metrics := face.Metrics()
-	textHeight := metrics.Ascent.Ceil() + metrics.Descent.Ceil()
+func NewInteraction(config ChartConfig, layout *Layout) *Interaction {
 	return &Interaction{
-		fontFace:      face,
-		labelHeight:   textHeight,
-		labelPadding:  5,
+		fonts:         newFontCache(12),
 		frameTimes:    make([]float64, 0, 300),
 		lastUpdate:    time.Now(),
 		config:        config,
+		layout:        layout,
 		snappedBarIdx: -1,
 	}
 }
 
 func (i *Interaction) Update(chart *Chart) {
+	i.fontFace = i.fonts.get(i.layout)
+	i.labelHeight = i.fonts.height
+	i.labelPadding = int(i.layout.Px(5))
 	i.updateFrameTimes()
 
 	// Store previous crosshair state
@@ -86,7 +77,13 @@ func (i *Interaction) updateFrameTimes() {
 }
 
 func (i *Interaction) updateCrosshairPosition(chart *Chart) {
-	cx, cy := ebiten.CursorPosition()
+	cx, cy := i.layout.CursorPosition()
+	if i.steppedTime != 0 {
+		if cx == i.stepCursorX && cy == i.stepCursorY && i.showStepped(chart) {
+			return
+		}
+		i.steppedTime = 0
+	}
 	mouseX, mouseY := float64(cx), float64(cy)
 
 	// Update both positions freely
@@ -94,31 +91,111 @@ func (i *Interaction) updateCrosshairPosition(chart *Chart) {
 	i.crosshairY = mouseY
 
 	// Calculate chart boundaries
-	chartLeft := chart.config.LeftMargin
-	chartRight := chart.config.Width - chart.config.RightMargin
-	chartTop := chart.config.TopMargin
-	chartBottom := chart.config.Height - chart.config.BottomMargin
+	chartLeft := chart.layout.LeftMargin
+	chartRight := chart.layout.Width - chart.layout.RightMargin
+	i.hoverPane = chart.panes.At(mouseY)
 
 	// Check if cursor is within chart bounds
-	i.showCrosshair = mouseX >= chartLeft && mouseX <= chartRight &&
-		mouseY >= chartTop && mouseY <= chartBottom
+	i.showCrosshair = mouseX >= chartLeft && mouseX <= chartRight && i.hoverPane != nil
 
+	i.linked = false
 	if i.showCrosshair {
 		i.updatePriceAndTimeValues(chart)
 	}
 }
 
-func (i *Interaction) updatePriceAndTimeValues(chart *Chart) {
-	chartHeight := chart.config.Height - chart.config.TopMargin - chart.config.BottomMargin
-	priceRange := chart.priceMax - chart.priceMin
-	i.mousePrice = chart.priceMax - ((i.crosshairY - chart.config.TopMargin) / chartHeight * priceRange)
+// ShowLinked shows a vertical crosshair over the bar containing t, following
+// the cursor in another chart
+func (i *Interaction) ShowLinked(chart *Chart, t int64) {
+	start, end := chart.visibleRange()
+	idx := chart.barAt(t)
+	if start == -1 || idx < start || idx >= end {
+		return
+	}
+	i.crosshairX = float64(chart.barX(idx - start))
+	i.mouseTime = chart.Data[idx].Time
+	i.showCrosshair = true
+	i.linked = true
+}
+
+// StepBar moves the crosshair delta bars from the bar it is on, or from the
+// last visible bar when it isn't shown
+func (i *Interaction) StepBar(chart *Chart, delta int) {
+	start, end := chart.visibleRange()
+	if start == -1 || end <= start {
+		return
+	}
+	idx := end - 1
+	if i.showCrosshair {
+		if bar := chart.barAt(i.mouseTime); bar != -1 {
+			idx = bar
+		}
+	}
+	i.StepTo(chart, max(0, min(idx+delta, len(chart.Data)-1)))
+}
+
+// StepTo puts the crosshair on bar idx at its close, scrolling the bar into
+// view, until the cursor moves
+func (i *Interaction) StepTo(chart *Chart, idx int) {
+	start, end := chart.visibleRange()
+	if start == -1 || idx < 0 || idx >= len(chart.Data) {
+		return
+	}
+	switch {
+	case idx < start:
+		chart.scrollTo(float64(idx))
+	case idx >= end:
+		chart.scrollTo(chart.position() + float64(idx-end+1))
+	}
+	i.steppedTime = chart.Data[idx].Time
+	i.stepCursorX, i.stepCursorY = i.layout.CursorPosition()
+	i.showStepped(chart)
+}
+
+// PinAt puts the crosshair on the bar under x, as StepTo does
+func (i *Interaction) PinAt(chart *Chart, x float64) {
+	i.StepTo(chart, chart.barIndexAt(x))
+}
+
+// Unpin hands the crosshair back to the cursor
+func (i *Interaction) Unpin() {
+	i.steppedTime = 0
+}
+
+// showStepped places the crosshair on the stepped bar, reporting false when
+// the bar is out of view
+func (i *Interaction) showStepped(chart *Chart) bool {
+	start, end := chart.visibleRange()
+	idx := chart.barAt(i.steppedTime)
+	if start == -1 || idx < start || idx >= end {
+		return false
+	}
+	bar := chart.Data[idx]
+	i.crosshairX = float64(chart.barX(idx - start))
+	i.crosshairY = float64(chart.priceToY(bar.Close))
+	i.hoverPane = chart.panes.Main()
+	i.mouseTime = bar.Time
+	i.mousePrice = bar.Close
+	i.valueText = chart.Scale.format(bar.Close)
+	i.showCrosshair = true
+	i.linked = false
+	return true
+}
 
-	chartWidth := chart.config.Width - chart.config.LeftMargin - chart.config.RightMargin
-	timeRange := float64(chart.timeEnd - chart.timeStart)
-	i.mouseTime = chart.timeStart + int64(((i.crosshairX-chart.config.LeftMargin)/chartWidth)*timeRange)
+func (i *Interaction) updatePriceAndTimeValues(chart *Chart) {
+	i.mouseTime = chart.xToTime(i.crosshairX)
+	if i.hoverPane.Content == nil {
+		i.mousePrice = chart.yToPrice(i.crosshairY)
+		i.valueText = chart.Scale.format(i.mousePrice)
+		return
+	}
+	// Indicator panes read out their own value, at a tenth of an axis step
+	pane := i.hoverPane
+	value := pane.yToValue(i.crosshairY)
+	i.valueText = pane.Content.FormatValue(value, calculateStep(pane.max-pane.min, 50))
 }
 
-func (i *Interaction) Draw(screen *ebiten.Image, chart *Chart) {
+func (i *Interaction) Draw(screen Renderer, chart *Chart) {
 	i.drawFrameTimeDisplay(screen)
 	if !i.showCrosshair {
 		return
@@ -126,18 +203,18 @@ func (i *Interaction) Draw(screen *ebiten.Image, chart *Chart) {
 	i.drawCrosshair(screen, chart)
 }
 
-func (i *Interaction) drawFrameTimeDisplay(screen *ebiten.Image) {
+func (i *Interaction) drawFrameTimeDisplay(screen Renderer) {
 	frametimeText := fmt.Sprintf("%.1f", i.frameTimeMA)
 	textWidth := font.MeasureString(i.fontFace, frametimeText).Ceil()
 	textHeight := i.labelHeight
 
-	padding := 4
+	padding := int(i.layout.Px(4))
 	rectWidth := textWidth + padding*2
 	rectHeight := textHeight + padding*2
-	rectX := float32(i.config.Width) - float32(rectWidth) - 2
+	rectX := float32(i.layout.Width) - float32(rectWidth) - 2
 	rectY := float32(2)
 
-	cx, cy := ebiten.CursorPosition()
+	cx, cy := i.layout.CursorPosition()
 	showUnits := cx >= int(rectX) && cx <= int(rectX)+rectWidth &&
 		cy >= int(rectY) && cy <= int(rectY)+rectHeight
 
@@ -145,21 +222,18 @@ func (i *Interaction) drawFrameTimeDisplay(screen *ebiten.Image) {
 		frametimeText += " ms"
 		textWidth = font.MeasureString(i.fontFace, frametimeText).Ceil()
 		rectWidth = textWidth + padding*2
-		rectX = float32(i.config.Width) - float32(rectWidth) - 2
+		rectX = float32(i.layout.Width) - float32(rectWidth) - 2
 	}
 
-	vector.DrawFilledRect(
-		screen,
+	screen.FillRect(
 		rectX,
 		rectY,
 		float32(rectWidth),
 		float32(rectHeight),
 		i.config.FrameTimeMABgColor,
-		false,
 	)
 
-	text.Draw(
-		screen,
+	screen.Text(
 		frametimeText,
 		i.fontFace,
 		int(rectX)+padding,
@@ -168,53 +242,49 @@ func (i *Interaction) drawFrameTimeDisplay(screen *ebiten.Image) {
 	)
 }
 
-func (i *Interaction) drawCrosshair(screen *ebiten.Image, chart *Chart) {
+func (i *Interaction) drawCrosshair(screen Renderer, chart *Chart) {
 	// Draw perfectly centered vertical line
-	vector.StrokeLine(
-		screen,
+	screen.StrokeLine(
 		float32(i.crosshairX),
-		float32(i.config.TopMargin),
+		float32(chart.panes.Top()),
 		float32(i.crosshairX),
-		float32(i.config.Height-i.config.BottomMargin),
-		1,
+		float32(chart.panes.Bottom()),
+		i.layout.Px32(1),
 		i.config.CrosshairColor,
-		false,
 	)
 
+	i.drawTimeLabel(screen)
+	if i.linked {
+		return
+	}
+
 	// Draw horizontal line
-	vector.StrokeLine(
-		screen,
-		float32(i.config.LeftMargin),
+	screen.StrokeLine(
+		float32(i.layout.LeftMargin),
 		float32(i.crosshairY),
-		float32(i.config.Width-i.config.RightMargin),
+		float32(i.layout.Width-i.layout.RightMargin),
 		float32(i.crosshairY),
-		1,
+		i.layout.Px32(1),
 		i.config.CrosshairColor,
-		false,
 	)
-
-	i.drawPriceLabel(screen)
-	i.drawTimeLabel(screen)
+	i.drawPriceLabel(screen, chart)
 }
 
-func (i *Interaction) drawPriceLabel(screen *ebiten.Image) {
-	priceText := fmt.Sprintf("%.2f", i.mousePrice)
+func (i *Interaction) drawPriceLabel(screen Renderer, chart *Chart) {
+	priceText := i.valueText
 	priceTextWidth := font.MeasureString(i.fontFace, priceText).Ceil()
-	priceTextX := int(i.config.LeftMargin) - priceTextWidth - i.labelPadding*2
+	priceTextX := int(i.layout.LeftMargin) - priceTextWidth - i.labelPadding*2
 	priceTextY := int(i.crosshairY) + i.labelHeight/2
 
-	vector.DrawFilledRect(
-		screen,
+	screen.FillRect(
 		float32(priceTextX-i.labelPadding),
 		float32(priceTextY-i.labelHeight),
 		float32(priceTextWidth+i.labelPadding*2),
 		float32(i.labelHeight+i.labelPadding),
 		i.config.CrosshairBgColor,
-		false,
 	)
 
-	text.Draw(
-		screen,
+	screen.Text(
 		priceText,
 		i.fontFace,
 		priceTextX,
@@ -223,31 +293,27 @@ func (i *Interaction) drawPriceLabel(screen *ebiten.Image) {
 	)
 }
 
-func (i *Interaction) drawTimeLabel(screen *ebiten.Image) {
-	t := time.Unix(i.mouseTime/1000, (i.mouseTime%1000)*int64(time.Millisecond))
-	timeText := t.Format("2006-01-02 15:04:05.000")
+func (i *Interaction) drawTimeLabel(screen Renderer) {
+	timeText := msToTime(i.mouseTime).Format(TimeFormat.DefaultFormat)
 	timeTextWidth := font.MeasureString(i.fontFace, timeText).Ceil()
 	timeTextX := int(math.Max(
-		i.config.LeftMargin,
+		i.layout.LeftMargin,
 		math.Min(
 			i.crosshairX-float64(timeTextWidth/2),
-			i.config.Width-i.config.RightMargin-float64(timeTextWidth),
+			i.layout.Width-i.layout.RightMargin-float64(timeTextWidth),
 		),
 	))
-	timeTextY := int(i.config.Height-i.config.BottomMargin) + i.labelHeight + i.labelPadding*2
+	timeTextY := int(i.layout.Height-i.layout.BottomMargin) + i.labelHeight + i.labelPadding*2
 
-	vector.DrawFilledRect(
-		screen,
+	screen.FillRect(
 		float32(timeTextX-i.labelPadding),
 		float32(timeTextY-i.labelHeight-i.labelPadding),
 		float32(timeTextWidth+i.labelPadding*2),
 		float32(i.labelHeight+i.labelPadding),
 		i.config.CrosshairBgColor,
-		false,
 	)
 
-	text.Draw(
-		screen,
+	screen.Text(
 		timeText,
 		i.fontFace,
 		timeTextX,
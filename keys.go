@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Action is something a key binding does
+type Action int
+
+const (
+	ActionScrollLeft Action = iota
+	ActionScrollRight
+	ActionPageLeft
+	ActionPageRight
+	ActionZoomIn
+	ActionZoomOut
+	ActionNextBar // Step the crosshair to the next bar
+	ActionPrevBar
+	ActionOldest
+	ActionLatest
+	ActionGoToDate
+	ActionExportSelection
+	ActionClearSelection
+	ActionMaximizePane // Maximize or restore the pane under the cursor
+	ActionRemovePane
+	ActionPaneUp
+	ActionPaneDown
+	ActionStyle
+	ActionScaleMode
+	ActionInvert
+	ActionLock
+	ActionCompareMode
+	ActionVolume
+	ActionQuoteVolume
+	ActionProfile
+	ActionGrid
+	ActionLinkCrosshair
+	ActionLinkTime
+	ActionTheme
+)
+
+// actionNames name the actions in key files
+var actionNames = []string{
+	"scroll_left", "scroll_right", "page_left", "page_right", "zoom_in", "zoom_out",
+	"next_bar", "prev_bar", "oldest", "latest", "go_to_date", "export_selection", "clear_selection",
+	"maximize_pane", "remove_pane", "pane_up", "pane_down",
+	"style", "scale_mode", "invert", "lock", "compare_mode", "volume", "quote_volume", "profile",
+	"grid", "link_crosshair", "link_time", "theme",
+}
+
+func (a Action) String() string {
+	return actionNames[a]
+}
+
+func (a *Action) UnmarshalText(text []byte) error {
+	for i, name := range actionNames {
+		if name == string(text) {
+			*a = Action(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown action %q", text)
+}
+
+// repeats reports whether the action fires again while its key is held
+func (a Action) repeats() bool {
+	return a <= ActionPrevBar
+}
+
+// Held keys repeat after keyRepeatDelay ticks, every keyRepeatInterval ticks
+const (
+	keyRepeatDelay    = 24
+	keyRepeatInterval = 3
+)
+
+// modifiers is a set of held modifier keys
+type modifiers uint8
+
+const (
+	modShift modifiers = 1 << iota
+	modControl
+	modAlt
+	modMeta
+)
+
+var modifierKeys = []struct {
+	mod  modifiers
+	name string
+	key  ebiten.Key
+}{
+	{modShift, "Shift", ebiten.KeyShift},
+	{modControl, "Control", ebiten.KeyControl},
+	{modAlt, "Alt", ebiten.KeyAlt},
+	{modMeta, "Meta", ebiten.KeyMeta},
+}
+
+// heldModifiers returns the modifier keys held down
+func heldModifiers() modifiers {
+	var mods modifiers
+	for _, m := range modifierKeys {
+		if ebiten.IsKeyPressed(m.key) {
+			mods |= m.mod
+		}
+	}
+	return mods
+}
+
+// KeyBinding is a key pressed with exactly the given modifiers held, written
+// as "ArrowLeft" or "Shift+ArrowLeft" in key files. Key names are
+// Ebitengine's.
+type KeyBinding struct {
+	Key  ebiten.Key
+	mods modifiers
+}
+
+func (b KeyBinding) String() string {
+	var s strings.Builder
+	for _, m := range modifierKeys {
+		if b.mods&m.mod != 0 {
+			s.WriteString(m.name + "+")
+		}
+	}
+	s.WriteString(b.Key.String())
+	return s.String()
+}
+
+func (b *KeyBinding) UnmarshalText(text []byte) error {
+	parts := strings.Split(string(text), "+")
+	*b = KeyBinding{}
+	for _, part := range parts[:len(parts)-1] {
+		found := false
+		for _, m := range modifierKeys {
+			if strings.EqualFold(part, m.name) {
+				b.mods |= m.mod
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown modifier %q in %q", part, text)
+		}
+	}
+	if err := b.Key.UnmarshalText([]byte(parts[len(parts)-1])); err != nil {
+		return fmt.Errorf("unknown key in %q", text)
+	}
+	return nil
+}
+
+// keyBindings reads one binding or a list of them
+type keyBindings []KeyBinding
+
+func (k *keyBindings) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var one KeyBinding
+		if err := json.Unmarshal(data, &one); err != nil {
+			return err
+		}
+		*k = keyBindings{one}
+		return nil
+	}
+	var list []KeyBinding
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*k = list
+	return nil
+}
+
+// KeyMap binds each action to the keys that trigger it
+type KeyMap map[Action][]KeyBinding
+
+func bind(names ...string) []KeyBinding {
+	bindings := make([]KeyBinding, len(names))
+	for i, name := range names {
+		if err := bindings[i].UnmarshalText([]byte(name)); err != nil {
+			panic(err)
+		}
+	}
+	return bindings
+}
+
+// DefaultKeys are the bindings used for actions a key file leaves out
+var DefaultKeys = KeyMap{
+	ActionScrollLeft:      bind("ArrowLeft"),
+	ActionScrollRight:     bind("ArrowRight"),
+	ActionPageLeft:        bind("Shift+ArrowLeft", "PageUp"),
+	ActionPageRight:       bind("Shift+ArrowRight", "PageDown"),
+	ActionZoomIn:          bind("Equal", "Shift+Equal", "NumpadAdd"),
+	ActionZoomOut:         bind("Minus", "NumpadSubtract"),
+	ActionNextBar:         bind("ArrowUp"),
+	ActionPrevBar:         bind("ArrowDown"),
+	ActionOldest:          bind("Home"),
+	ActionLatest:          bind("End"),
+	ActionGoToDate:        bind("D"),
+	ActionExportSelection: bind("E"),
+	ActionClearSelection:  bind("Escape"),
+	ActionMaximizePane:    bind("M"),
+	ActionRemovePane:      bind("Delete"),
+	ActionPaneUp:          bind("Control+ArrowUp"),
+	ActionPaneDown:        bind("Control+ArrowDown"),
+	ActionStyle:           bind("S"),
+	ActionScaleMode:       bind("P"),
+	ActionInvert:          bind("I"),
+	ActionLock:            bind("L"),
+	ActionCompareMode:     bind("C"),
+	ActionVolume:          bind("V"),
+	ActionQuoteVolume:     bind("Q"),
+	ActionProfile:         bind("F"),
+	ActionGrid:            bind("G"),
+	ActionLinkCrosshair:   bind("X"),
+	ActionLinkTime:        bind("Z"),
+	ActionTheme:           bind("T"),
+}
+
+// LoadKeyMap reads a JSON key file mapping action names to a key or a list
+// of keys, such as {"zoom_in": ["Equal", "NumpadAdd"], "oldest": "Control+Home"}.
+// Listed actions replace their default bindings; an empty list unbinds one.
+func LoadKeyMap(path string) (KeyMap, error) {
+	keys := KeyMap{}
+	for a, bindings := range DefaultKeys {
+		keys[a] = bindings
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return keys, nil
+	}
+	if err != nil {
+		return keys, fmt.Errorf("failed to read key bindings: %v", err)
+	}
+	var file map[Action]keyBindings
+	if err := json.Unmarshal(data, &file); err != nil {
+		return keys, fmt.Errorf("failed to parse key bindings %s: %v", path, err)
+	}
+	for a, bindings := range file {
+		keys[a] = bindings
+	}
+	return keys, nil
+}
+
+// startKeys loads the key file, falling back to the default bindings
+func startKeys(path string) KeyMap {
+	keys, err := LoadKeyMap(path)
+	if err != nil {
+		log.Printf("Using default key bindings: %v", err)
+		return DefaultKeys
+	}
+	return keys
+}
+
+// pressed reports whether one of the action's keys was pressed this tick
+// with exactly its modifiers held, counting repeats for held keys of
+// repeating actions
+func (m KeyMap) pressed(a Action) bool {
+	mods := heldModifiers()
+	for _, b := range m[a] {
+		if b.mods != mods {
+			continue
+		}
+		if inpututil.IsKeyJustPressed(b.Key) || a.repeats() && keyRepeated(b.Key) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyRepeated reports whether a held key repeats this tick
+func keyRepeated(key ebiten.Key) bool {
+	d := inpututil.KeyPressDuration(key)
+	return d >= keyRepeatDelay && (d-keyRepeatDelay)%keyRepeatInterval == 0
+}
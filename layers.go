@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// layer is an offscreen image holding one part of a chart. It is redrawn
+// only when the key describing what it shows changes, so moving the cursor
+// just composites the cached layers under the crosshair.
+type layer struct {
+	image *ebiten.Image
+	key   any // Comparable key of the last render, nil when invalid
+}
+
+// update redraws the layer at the given size if key differs from the one
+// it was last drawn with
+func (l *layer) update(width, height int, key any, draw func(screen Renderer)) {
+	if l.image == nil || l.image.Bounds().Dx() != width || l.image.Bounds().Dy() != height {
+		if l.image != nil {
+			l.image.Deallocate()
+		}
+		l.image = ebiten.NewImage(width, height)
+		l.key = nil
+	}
+	if l.key == key {
+		return
+	}
+	l.image.Clear()
+	draw(newEbitenRenderer(l.image))
+	l.key = key
+}
+
+// drawTo composites the layer onto dst
+func (l *layer) drawTo(dst *ebiten.Image) {
+	if l.image != nil {
+		dst.DrawImage(l.image, nil)
+	}
+}
+
+// chartLayers are a view's cached layers, bottom to top
+type chartLayers struct {
+	grid     layer // Background, time segments and grid lines
+	bars     layer // Series and indicator panes such as volume
+	overlays layer // Compare series and other overlays
+	labels   layer // Axes, labels, price tags and the legend
+}
+
+func (ls *chartLayers) all() []*layer {
+	return []*layer{&ls.grid, &ls.bars, &ls.overlays, &ls.labels}
+}
+
+// sceneKey captures what every layer depends on: the size, theme, data,
+// horizontal and vertical viewport and the pane arrangement
+type sceneKey struct {
+	width, height float64
+	dpi           float64
+	theme         string
+	data          *OHLCV // First bar, identifies the slice
+	length        int
+	last          OHLCV // The last bar changes while it is forming
+	from          int64
+	scroll        float64
+	zoom          float64
+	viewMin       float64
+	viewMax       float64
+	scale         PriceScale
+	panes         string
+	volume        volumeSceneKey
+}
+
+// volumeSceneKey covers the volume settings and the overlay band's scale
+type volumeSceneKey struct {
+	placement VolumePlacement
+	quote     bool
+	top       float64
+	min, max  float64
+}
+
+func (v *ChartView) sceneKey() sceneKey {
+	c := v.chart
+	key := sceneKey{
+		width:   v.layout.Width,
+		height:  v.layout.Height,
+		dpi:     v.layout.Scale,
+		theme:   v.config.Theme,
+		length:  len(c.Data),
+		from:    c.ts_from,
+		scroll:  c.scroll,
+		zoom:    c.Zoom,
+		viewMin: c.viewMin,
+		viewMax: c.viewMax,
+		scale:   c.Scale,
+		panes:   c.panes.key(),
+		volume: volumeSceneKey{
+			placement: v.volumePlacement,
+			quote:     v.volume.Quote,
+			top:       v.volume.band.Top,
+			min:       v.volume.band.min,
+			max:       v.volume.band.max,
+		},
+	}
+	if len(c.Data) > 0 {
+		key.data, key.last = &c.Data[0], c.Data[len(c.Data)-1]
+	}
+	return key
+}
+
+// Keys of the individual layers, adding what only that layer shows
+type (
+	barsLayerKey struct {
+		scene sceneKey
+		style SeriesStyle
+	}
+	overlaysLayerKey struct {
+		scene    sceneKey
+		revision int
+	}
+	labelsLayerKey struct {
+		scene    sceneKey
+		revision int   // The legend lists the compare series
+		second   int64 // The bar-close countdown ticks every second
+	}
+)
+
+// key describes the pane arrangement and indicator scales
+func (p *Panes) key() string {
+	var b strings.Builder
+	for _, pane := range p.panes {
+		name := ""
+		if pane.Content != nil {
+			name = pane.Content.Name()
+		}
+		fmt.Fprintf(&b, "%s %g %g %g %g;", name, pane.Top, pane.Bottom, pane.min, pane.max)
+	}
+	return b.String()
+}
+
+// updateLayers redraws the layers whose inputs changed since their last
+// render
+func (v *ChartView) updateLayers(width, height int) {
+	scene := v.sceneKey()
+	labels := labelsLayerKey{scene: scene, revision: v.chart.overlaysRev}
+	if v.config.ShowRightAxis {
+		labels.second = time.Now().Unix()
+	}
+
+	v.layers.grid.update(width, height, scene, v.drawGrid)
+	v.layers.bars.update(width, height, barsLayerKey{scene, v.chart.Style}, v.drawBars)
+	v.layers.overlays.update(width, height, overlaysLayerKey{scene, v.chart.overlaysRev}, v.drawOverlays)
+	v.layers.labels.update(width, height, labels, v.drawLabels)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"golang.org/x/image/font"
+)
+
+// Layout holds the live screen dimensions in device pixels. It is shared by
+// the chart components and refreshed from Game.Layout on every resize or
+// device scale change, with the ChartConfig sizes multiplied by Scale.
+type Layout struct {
+	Scale float64 // Device scale factor
+
+	// Position of this layout's top-left corner in the window, for charts
+	// that share the window with others
+	OriginX float64
+	OriginY float64
+
+	Width        float64
+	Height       float64
+	LeftMargin   float64
+	RightMargin  float64
+	TopMargin    float64
+	BottomMargin float64
+
+	BarWidth            float64
+	BarSpacing          float64
+	BodySpacing         float64
+	VolumeSpacing       float64
+	MinTimeLabelSpacing float64
+
+	LineWidth float32
+	AxisWidth float32
+	GridWidth float32
+
+	config ChartConfig
+}
+
+func NewLayout(config ChartConfig) *Layout {
+	l := &Layout{config: config}
+	l.Resize(config.Width, config.Height, 1)
+	return l
+}
+
+// Resize updates the layout for a window of width x height logical pixels
+// shown at the given device scale factor
+func (l *Layout) Resize(width, height, scale float64) {
+	if scale <= 0 {
+		scale = 1
+	}
+	l.Scale = scale
+	l.Width = math.Ceil(width * scale)
+	l.Height = math.Ceil(height * scale)
+
+	l.LeftMargin = l.config.LeftMargin * scale
+	l.RightMargin = l.config.RightMargin * scale
+	l.TopMargin = l.config.TopMargin * scale
+	l.BottomMargin = l.config.BottomMargin * scale
+
+	l.BarWidth = l.config.BarWidth * scale
+	l.BarSpacing = l.config.BarSpacing * scale
+	l.BodySpacing = l.config.BodySpacing * scale
+	l.VolumeSpacing = l.config.VolumeSpacing * scale
+	l.MinTimeLabelSpacing = l.config.MinTimeLabelSpacing * scale
+
+	l.LineWidth = l.config.LineWidth * float32(scale)
+	l.AxisWidth = l.config.AxisWidth * float32(scale)
+	l.GridWidth = l.config.GridWidth * float32(scale)
+}
+
+// SetOrigin places the layout at x, y device pixels in the window
+func (l *Layout) SetOrigin(x, y float64) {
+	l.OriginX, l.OriginY = x, y
+}
+
+// CursorPosition returns the cursor relative to the layout's origin
+func (l *Layout) CursorPosition() (int, int) {
+	cx, cy := ebiten.CursorPosition()
+	return cx - int(l.OriginX), cy - int(l.OriginY)
+}
+
+// Contains reports whether the window position x, y falls inside the layout
+func (l *Layout) Contains(x, y int) bool {
+	fx, fy := float64(x)-l.OriginX, float64(y)-l.OriginY
+	return fx >= 0 && fy >= 0 && fx < l.Width && fy < l.Height
+}
+
+// Px scales a length in logical pixels to device pixels
+func (l *Layout) Px(v float64) float64 {
+	return v * l.Scale
+}
+
+// Px32 is Px for vector stroke widths and offsets
+func (l *Layout) Px32(v float32) float32 {
+	return v * float32(l.Scale)
+}
+
+// fontCache re-creates a font face whenever the device scale changes
+type fontCache struct {
+	size   float64
+	scale  float64
+	face   font.Face
+	height int // Ascent plus descent of face
+}
+
+func newFontCache(size float64) *fontCache {
+	return &fontCache{size: size}
+}
+
+// get returns the face for the layout's current scale
+func (f *fontCache) get(l *Layout) font.Face {
+	if f.face == nil || f.scale != l.Scale {
+		f.face = loadFont(f.size * l.Scale)
+		f.scale = l.Scale
+		metrics := f.face.Metrics()
+		f.height = metrics.Ascent.Ceil() + metrics.Descent.Ceil()
+	}
+	return f.face
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"math"
+)
+
+// VolumeDecimation selects how the volumes of bars sharing a pixel column
+// are combined
+type VolumeDecimation int
+
+const (
+	// VolumeMax keeps the tallest bar, which is what drawing every bar shows
+	VolumeMax VolumeDecimation = iota
+	// VolumeSum adds the bars up, as a longer interval would
+	VolumeSum
+)
+
+// drawBar is a bar as it is drawn: a single bar, or when zoomed out far
+// enough that several bars fall on one pixel column, their combination.
+// Open is the first bar's open and Close the last bar's close; High and Low
+// span the column, and the body spans every body in it. A combined body takes
+// the colour of the column's net change, where drawing every bar would show
+// the colour of whichever body was drawn last.
+type drawBar struct {
+	OHLCV
+	x float32
+	// Lowest and highest body edge within the column
+	bodyLow  float64
+	bodyHigh float64
+	// Closes within the column, so close lines keep their extremes
+	firstClose float64
+	minClose   float64
+	maxClose   float64
+	minFirst   bool // The lowest close came before the highest
+}
+
+// lodKey identifies the input of the decimated bars
+type lodKey struct {
+	data       *OHLCV
+	last       OHLCV
+	start, end int
+	zoom       float64
+	scroll     float64
+	width      float64
+	dpi        float64
+	volume     VolumeDecimation
+}
+
+// visibleBars returns the bars start..end-1 as drawn. Bars are combined per
+// pixel column when the bar spacing drops below a device pixel, so the cost
+// of drawing follows the chart width rather than the bar count. The result
+// is cached until the data or the viewport changes.
+func (c *Chart) visibleBars(start, end int) []drawBar {
+	key := lodKey{
+		start: start, end: end,
+		zoom: c.Zoom, scroll: c.scroll,
+		width: c.layout.Width, dpi: c.layout.Scale,
+		volume: c.config.VolumeDecimation,
+	}
+	if len(c.Data) > 0 {
+		key.data, key.last = &c.Data[0], c.Data[len(c.Data)-1]
+	}
+	if key == c.lodKey && c.lod != nil {
+		return c.lod
+	}
+
+	bars := c.lod[:0]
+	column := math.Inf(-1)
+	for i := start; i < end; i++ {
+		d := c.Data[i]
+		x := c.barX(i - start)
+		col := math.Floor(float64(x))
+		if col != column || len(bars) == 0 {
+			column = col
+			bars = append(bars, drawBar{
+				OHLCV:      d,
+				x:          x,
+				bodyLow:    math.Min(d.Open, d.Close),
+				bodyHigh:   math.Max(d.Open, d.Close),
+				firstClose: d.Close,
+				minClose:   d.Close,
+				maxClose:   d.Close,
+			})
+			continue
+		}
+
+		// Same column as the previous bar, fold it in
+		b := &bars[len(bars)-1]
+		b.High = math.Max(b.High, d.High)
+		b.Low = math.Min(b.Low, d.Low)
+		b.Close = d.Close
+		b.bodyLow = math.Min(b.bodyLow, math.Min(d.Open, d.Close))
+		b.bodyHigh = math.Max(b.bodyHigh, math.Max(d.Open, d.Close))
+		if c.config.VolumeDecimation == VolumeSum {
+			b.Volume += d.Volume
+		} else {
+			b.Volume = math.Max(b.Volume, d.Volume)
+		}
+		if d.Close < b.minClose {
+			b.minClose, b.minFirst = d.Close, false
+		}
+		if d.Close > b.maxClose {
+			b.maxClose, b.minFirst = d.Close, true
+		}
+	}
+
+	c.lod, c.lodKey = bars, key
+	return bars
+}
+
+// closePoints returns the close line's vertices for bars start..end-1. A
+// column of combined bars contributes its first, lowest, highest and last
+// close in time order, so the line covers the same pixels as at full
+// resolution.
+func (c *Chart) closePoints(start, end int) [][2]float32 {
+	var points [][2]float32
+	for _, b := range c.visibleBars(start, end) {
+		add := func(price float64) {
+			y := c.priceToY(price)
+			if n := len(points); n > 0 && points[n-1] == [2]float32{b.x, y} {
+				return
+			}
+			points = append(points, [2]float32{b.x, y})
+		}
+		add(b.firstClose)
+		if b.minFirst {
+			add(b.minClose)
+			add(b.maxClose)
+		} else {
+			add(b.maxClose)
+			add(b.minClose)
+		}
+		add(b.Close)
+	}
+	return points
+}
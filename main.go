@@ -2,88 +2,252 @@ package main
 
 import (
 	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
 type Game struct {
-	chart           *Chart
-	axes            *Axes
-	interaction     *Interaction
-	volume          *Volume
-	db              *Database
-	timeframe       *Timeframe
-	lastUpdate      time.Time
-	needsRedraw     bool
-	prevMouseX      int
-	prevMouseY      int
-	prevFetchStatus string
-	prevErrorMsg    string
+	config        ChartConfig
+	specs         []ChartSpec
+	views         []*ChartView
+	focus         *ChartView // Chart taking the keyboard, the last one hovered
+	keys          KeyMap
+	touchView     *ChartView // Chart the fingers went down on, until all are lifted
+	touchIDs      []ebiten.TouchID
+	touches       []touchPoint
+	databases     map[string]*Database
+	gridSize      int  // Number of charts shown
+	linkCrosshair bool // Mirror the crosshair time across charts
+	linkTime      bool // Mirror scrolling and zoom across charts
+	width         float64
+	height        float64
+	scale         float64
+	needsRedraw   bool
+	prevMouseX    int
+	prevMouseY    int
+	prevSecond    int64
+	prevActive    *ChartView
+	prevViewport  viewport
+	offline       bool // Open stores without fetching in the background
 }
 
+// gridSizes are the chart counts the grid cycles through
+var gridSizes = []int{1, 2, 4, 6}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		if err := runRender(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Disable screen clearing optimization to ensure initial draw
 	ebiten.SetScreenClearedEveryFrame(false)
 
-	ebiten.SetWindowSize(1000, 700)
+	config := DefaultConfig
+	theme := startTheme(config.ThemeDir, config.Theme)
+	theme.Apply(&config)
+	ebiten.SetWindowSize(int(config.Width), int(config.Height))
 	ebiten.SetWindowTitle("OHLC Chart Viewer")
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
 
-	config := DefaultConfig
-	chart := NewChart(config)
+	game := &Game{
+		config:        config,
+		specs:         DefaultCharts,
+		keys:          startKeys(config.KeysFile),
+		databases:     make(map[string]*Database),
+		gridSize:      1,
+		linkCrosshair: true,
+		linkTime:      false,
+		needsRedraw:   true, // Ensure initial render
+		prevMouseX:    -1,
+		prevMouseY:    -1,
+	}
+	defer game.Close()
 
-	// Initialize database
-	db, err := NewDatabase()
+	// Fetch fresh data before starting the game. If the store's background
+	// fetch started first this returns at once, and the chart loads its bars
+	// when that fetch finishes.
+	_, stores, err := game.source(game.specs[0].Symbol)
 	if err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
-	defer db.Close()
+	log.Println("Fetching initial data...")
+	refreshStores(stores)
+	if err := game.ensureViews(); err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
 
-	timeframe := NewTimeframe(db.db)
+	if err := ebiten.RunGame(game); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	// Fetch fresh data before starting the game
-	log.Println("Fetching initial data...")
-	if err := db.ensureLastData(); err != nil {
-		log.Printf("Failed to fetch initial data: %v", err)
+// database returns the store for symbol, opening it on first use
+func (g *Game) database(symbol string) (*Database, error) {
+	if db, ok := g.databases[symbol]; ok {
+		return db, nil
+	}
+	open := NewDatabase
+	if g.offline {
+		open = openDatabase
 	}
-	data, err := timeframe.Get15MinBars()
+	db, err := open(symbol)
 	if err != nil {
-		log.Printf("No initial 15-min bars available: %v", err)
-		data = []OHLCV{}
+		return nil, err
 	}
-	chart.UpdateData(data)
+	g.databases[symbol] = db
+	return db, nil
+}
 
-	// Create game instance with fresh data
-	game := &Game{
-		chart:           chart,
-		axes:            NewAxes(config),
-		interaction:     NewInteraction(config),
-		volume:          NewVolume(config),
-		db:              db,
-		timeframe:       timeframe,
-		lastUpdate:      time.Now(),
-		needsRedraw:     true, // Ensure initial render
-		prevMouseX:      -1,
-		prevMouseY:      -1,
-		prevFetchStatus: "",
-		prevErrorMsg:    "",
+// source resolves a chart symbol, which may name a synthetic definition, be
+// an expression over stored symbols, or be a stored symbol
+func (g *Game) source(symbol string) (MinuteSource, []*Database, error) {
+	def, ok := findSynthetic(symbol)
+	if !ok && isExpr(symbol) {
+		def, ok = SyntheticDef{Name: symbol, Expr: symbol}, true
+	}
+	if ok {
+		s, err := NewSynthetic(def, g.database)
+		if err != nil {
+			return nil, nil, err
+		}
+		return s, s.Stores(), nil
+	}
+	db, err := g.database(symbol)
+	if err != nil {
+		return nil, nil, err
 	}
+	return db, []*Database{db}, nil
+}
 
-	if err := ebiten.RunGame(game); err != nil {
-		log.Fatal(err)
+// ensureViews creates the charts the current grid size needs
+func (g *Game) ensureViews() error {
+	for len(g.views) < g.gridSize && len(g.views) < len(g.specs) {
+		spec := g.specs[len(g.views)]
+		source, stores, err := g.source(spec.Symbol)
+		if err != nil {
+			return err
+		}
+		view := NewChartView(g.config, spec, source, stores)
+		for _, symbol := range spec.Compare {
+			source, stores, err := g.source(symbol)
+			if err != nil {
+				return err
+			}
+			view.AddCompare(symbol, source, stores)
+		}
+		g.views = append(g.views, view)
+	}
+	return nil
+}
+
+// visibleViews returns the charts shown in the grid
+func (g *Game) visibleViews() []*ChartView {
+	return g.views[:min(g.gridSize, len(g.views))]
+}
+
+// handleTouches passes the fingers on the screen to the chart the first of
+// them went down on, which also takes the keyboard
+func (g *Game) handleTouches(now time.Time) bool {
+	g.touchIDs = ebiten.AppendTouchIDs(g.touchIDs[:0])
+	sort.Slice(g.touchIDs, func(i, j int) bool { return g.touchIDs[i] < g.touchIDs[j] })
+	if g.touchView == nil {
+		if len(g.touchIDs) == 0 {
+			return false
+		}
+		x, y := ebiten.TouchPosition(g.touchIDs[0])
+		g.touchView = g.viewAt(x, y)
+		if g.touchView == nil {
+			return false
+		}
+		g.focus = g.touchView
+	}
+	view := g.touchView
+	g.touches = appendTouches(g.touches[:0], g.touchIDs, view.layout)
+	changed := view.handleTouches(now, g.touches)
+	if changed {
+		view.needsRedraw = true
+	}
+	if len(g.touchIDs) == 0 {
+		g.touchView = nil
+	}
+	return changed
+}
+
+// focusView returns the chart taking the keyboard: the last one hovered
+// while it is shown, else the first
+func (g *Game) focusView() *ChartView {
+	views := g.visibleViews()
+	for _, v := range views {
+		if v == g.focus {
+			return v
+		}
+	}
+	if len(views) == 0 {
+		return nil
+	}
+	return views[0]
+}
+
+// activeView returns the chart under the cursor, or nil
+func (g *Game) activeView() *ChartView {
+	for _, v := range g.visibleViews() {
+		if v.Hovered() {
+			return v
+		}
+	}
+	return nil
+}
+
+// viewAt returns the chart at window position x, y, or nil
+func (g *Game) viewAt(x, y int) *ChartView {
+	for _, v := range g.visibleViews() {
+		if v.layout.Contains(x, y) {
+			return v
+		}
+	}
+	return nil
+}
+
+func (g *Game) Close() {
+	for _, db := range g.databases {
+		db.Close()
 	}
 }
 
 func (g *Game) Update() error {
 	inputDetected := false
+	now := time.Now()
+	active := g.activeView()
+	if active != nil {
+		g.focus = active
+	}
+	if g.handleTouches(now) {
+		inputDetected = true
+	}
+	focus := g.focusView()
 
-	// Check keyboard input
-	if inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) || inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) {
+	// Check keyboard input, which an open prompt takes all of
+	switch {
+	case focus != nil && focus.prompt != nil:
+		focus.updatePrompt()
+	case g.handleGridKeys():
+		inputDetected = true
+	case focus != nil && focus.handleKeys(g.keys):
+		focus.needsRedraw = true
 		inputDetected = true
 	}
 	// Check mouse input
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) || ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) || inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) {
 		inputDetected = true
 	}
 	if _, dy := ebiten.Wheel(); dy != 0 {
@@ -96,77 +260,239 @@ func (g *Game) Update() error {
 	}
 	g.prevMouseX, g.prevMouseY = cx, cy
 
-	// Check touch input
-	var touchIDs []ebiten.TouchID
-	touchIDs = ebiten.AppendTouchIDs(touchIDs)
-	for _, id := range touchIDs {
-		if inpututil.TouchPressDuration(id) == 1 {
-			inputDetected = true
-			break
+	for _, v := range g.visibleViews() {
+		if err := v.Update(now); err != nil {
+			return err
+		}
+		// The hovered chart and one being dragged follow the input
+		if inputDetected && (v == active || v.chart.dragMode != dragNone) {
+			v.needsRedraw = true
+		}
+	}
+	g.linkViews(focus, inputDetected)
+
+	// Redraw every second while the bar-close countdown is shown
+	if g.config.ShowRightAxis && now.Unix() != g.prevSecond {
+		for _, v := range g.visibleViews() {
+			v.needsRedraw = true
 		}
+		g.prevSecond = now.Unix()
 	}
 
-	// Check for changes in fetch status or error message
-	g.db.fetchMutex.Lock()
-	currentFetchStatus := g.db.fetchStatus
-	currentErrorMsg := g.db.errorMsg
-	fetching := g.db.fetching
-	g.db.fetchMutex.Unlock()
+	return nil
+}
 
-	// Force redraw if fetching is in progress or status/error changed
-	if fetching || currentFetchStatus != g.prevFetchStatus || currentErrorMsg != g.prevErrorMsg {
-		g.needsRedraw = true
-		g.prevFetchStatus = currentFetchStatus
-		g.prevErrorMsg = currentErrorMsg
+// linkViews mirrors the active chart's crosshair and viewport onto the others
+func (g *Game) linkViews(active *ChartView, inputDetected bool) {
+	if active == nil {
+		g.prevActive = nil
+		return
 	}
 
-	// Auto-refresh data periodically
-	now := time.Now()
-	if now.Sub(g.lastUpdate) > time.Minute {
-		data, err := g.timeframe.Get15MinBars()
-		if err != nil {
-			log.Printf("Failed to refresh 15-min bars: %v", err)
-			if err := g.db.ensureLastData(); err != nil {
-				log.Printf("Failed to update database: %v", err)
+	if g.linkCrosshair && active.interaction.showCrosshair {
+		for _, v := range g.visibleViews() {
+			if v == active {
+				continue
 			}
-			data, err = g.timeframe.Get15MinBars()
-			if err != nil {
-				log.Printf("Retry failed: %v", err)
-				return nil
+			v.interaction.ShowLinked(v.chart, active.interaction.mouseTime)
+			if inputDetected {
+				v.needsRedraw = true
 			}
 		}
-		g.chart.UpdateData(data)
-		inputDetected = true
-		g.lastUpdate = now
 	}
 
-	if err := g.chart.Update(); err != nil {
-		return err
+	vp := active.viewport()
+	if g.linkTime && active == g.prevActive && vp != g.prevViewport {
+		for _, v := range g.visibleViews() {
+			if v != active {
+				v.chart.SyncTo(active.chart)
+				v.needsRedraw = true
+			}
+		}
 	}
-	g.axes.Update(g.chart)
-	g.interaction.Update(g.chart)
+	g.prevActive, g.prevViewport = active, vp
+}
 
-	if inputDetected || g.interaction.showCrosshair != g.interaction.prevShowCrosshair {
-		g.needsRedraw = true
+// handleGridKeys cycles the grid size, toggles crosshair and time linking
+// and cycles the theme
+func (g *Game) handleGridKeys() bool {
+	switch {
+	case g.keys.pressed(ActionGrid):
+		for i, size := range gridSizes {
+			if size == g.gridSize {
+				g.gridSize = gridSizes[(i+1)%len(gridSizes)]
+				break
+			}
+		}
+		if err := g.ensureViews(); err != nil {
+			log.Printf("Failed to open chart: %v", err)
+		}
+		g.arrangeViews()
+	case g.keys.pressed(ActionLinkCrosshair):
+		g.linkCrosshair = !g.linkCrosshair
+	case g.keys.pressed(ActionLinkTime):
+		g.linkTime = !g.linkTime
+	case g.keys.pressed(ActionTheme):
+		g.applyTheme(nextTheme(g.config.Theme))
+	default:
+		return false
 	}
+	g.needsRedraw = true
+	for _, v := range g.visibleViews() {
+		v.needsRedraw = true
+	}
+	return true
+}
 
-	return nil
+// applyTheme switches every chart to theme, including ones not shown
+func (g *Game) applyTheme(theme Theme) {
+	theme.Apply(&g.config)
+	for _, v := range g.views {
+		v.ApplyTheme(theme)
+	}
+}
+
+// handleKeys applies the per-chart shortcuts to the view
+func (v *ChartView) handleKeys(keys KeyMap) bool {
+	c := v.chart
+	hover := c.panes.Hovered()
+	switch {
+	case keys.pressed(ActionScrollLeft):
+		c.scrollBars(-1)
+	case keys.pressed(ActionScrollRight):
+		c.scrollBars(1)
+	case keys.pressed(ActionPageLeft):
+		c.scrollBars(-c.pageBars())
+	case keys.pressed(ActionPageRight):
+		c.scrollBars(c.pageBars())
+	case keys.pressed(ActionZoomIn):
+		c.zoomAt(v.zoomAnchor(), 1.1)
+	case keys.pressed(ActionZoomOut):
+		c.zoomAt(v.zoomAnchor(), 1/1.1)
+	case keys.pressed(ActionNextBar):
+		v.interaction.StepBar(c, 1)
+	case keys.pressed(ActionPrevBar):
+		v.interaction.StepBar(c, -1)
+	case keys.pressed(ActionOldest):
+		if err := v.goToOldest(); err != nil {
+			log.Printf("Failed to load the oldest bars: %v", err)
+		}
+	case keys.pressed(ActionLatest):
+		c.scrollToLatest()
+	case keys.pressed(ActionGoToDate):
+		v.prompt = newDatePrompt()
+	case keys.pressed(ActionExportSelection):
+		path, err := v.exportSelection()
+		if err != nil {
+			log.Printf("Failed to export selection: %v", err)
+			v.selection.SetStatus(c, "Export failed")
+			break
+		}
+		v.selection.SetStatus(c, "Saved "+filepath.Base(path))
+	case keys.pressed(ActionClearSelection):
+		c.selection = timeRange{}
+	case hover != nil && keys.pressed(ActionMaximizePane):
+		c.panes.ToggleMaximize(hover)
+	case hover != nil && keys.pressed(ActionRemovePane):
+		c.panes.Remove(hover)
+	case hover != nil && keys.pressed(ActionPaneUp):
+		c.panes.Move(hover, -1)
+	case hover != nil && keys.pressed(ActionPaneDown):
+		c.panes.Move(hover, 1)
+	case keys.pressed(ActionStyle):
+		c.CycleStyle()
+	case keys.pressed(ActionScaleMode):
+		c.CycleScaleMode()
+	case keys.pressed(ActionInvert):
+		c.ToggleInverted()
+	case keys.pressed(ActionLock):
+		c.ToggleLock()
+	case keys.pressed(ActionCompareMode):
+		c.cycleCompareMode()
+	case keys.pressed(ActionVolume):
+		v.setVolumePlacement((v.volumePlacement + 1) % volumePlacementCount)
+	case keys.pressed(ActionQuoteVolume):
+		v.volume.Quote = !v.volume.Quote
+	case keys.pressed(ActionProfile):
+		v.setProfile((v.profile + 1) % profileKindCount)
+	default:
+		return false
+	}
+	return true
 }
 
 func (g *Game) Draw(screen *ebiten.Image) {
-	if !g.needsRedraw {
+	redraw := g.needsRedraw
+	for _, v := range g.visibleViews() {
+		if v.needsRedraw {
+			redraw = true
+		}
+		v.Draw()
+	}
+	if !redraw {
 		return
 	}
 
-	screen.Fill(g.chart.config.BackgroundColor)
-	g.axes.Draw(screen, g.chart)
-	g.volume.Draw(screen, g.chart)
-	g.chart.Draw(screen)
-	g.interaction.Draw(screen, g.chart)
-	g.db.DrawError(screen)
+	screen.Fill(g.config.BackgroundColor)
+	for _, v := range g.visibleViews() {
+		if v.image == nil {
+			continue
+		}
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Translate(v.layout.OriginX, v.layout.OriginY)
+		screen.DrawImage(v.image, op)
+	}
+
+	// Separate the charts in the grid
+	cols, rows := gridShape(g.gridSize)
+	for c := 1; c < cols; c++ {
+		x := float32(math.Floor(g.width * float64(c) / float64(cols)))
+		vector.StrokeLine(screen, x, 0, x, float32(g.height), float32(g.scale), g.config.AxisColor, false)
+	}
+	for r := 1; r < rows; r++ {
+		y := float32(math.Floor(g.height * float64(r) / float64(rows)))
+		vector.StrokeLine(screen, 0, y, float32(g.width), y, float32(g.scale), g.config.AxisColor, false)
+	}
 	g.needsRedraw = false
 }
 
+// gridShape returns the columns and rows used to tile n charts
+func gridShape(n int) (cols, rows int) {
+	switch {
+	case n <= 1:
+		return 1, 1
+	case n == 2:
+		return 2, 1
+	case n <= 4:
+		return 2, 2
+	default:
+		return 3, 2
+	}
+}
+
+// arrangeViews tiles the visible charts across the window
+func (g *Game) arrangeViews() {
+	cols, rows := gridShape(g.gridSize)
+	for i, v := range g.visibleViews() {
+		col, row := i%cols, i/cols
+		x0 := math.Floor(g.width * float64(col) / float64(cols))
+		x1 := math.Floor(g.width * float64(col+1) / float64(cols))
+		y0 := math.Floor(g.height * float64(row) / float64(rows))
+		y1 := math.Floor(g.height * float64(row+1) / float64(rows))
+		v.Place(x0, y0, x1-x0, y1-y0, g.scale)
+	}
+}
+
+// Layout reports the window size in device pixels so the charts render
+// sharply on HiDPI screens, and re-tiles them when the size changes
 func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
-	return 1000, 700
+	scale := ebiten.Monitor().DeviceScaleFactor()
+	width := math.Ceil(float64(outsideWidth) * scale)
+	height := math.Ceil(float64(outsideHeight) * scale)
+	if width != g.width || height != g.height || scale != g.scale {
+		g.width, g.height, g.scale = width, height, scale
+		g.needsRedraw = true
+	}
+	g.arrangeViews()
+	return int(width), int(height)
 }
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"sort"
+)
+
+const minuteMs = 60 * 1000
+
+// minuteCache keeps a contiguous span of a source's 1-minute bars in memory,
+// so profiles built from them can follow scrolling without rereading the
+// store for minutes already seen. The store is read a minute at a time, so
+// minutes not yet cached are read in the background.
+type minuteCache struct {
+	source   MinuteSource
+	from, to int64       // Span read, to exclusive
+	bars     []OHLCV     // Stored minutes within the span, in time order
+	pending  *minuteRead // Read running in the background, nil when idle
+	Blocking bool        // Read on the caller's goroutine, for headless rendering
+}
+
+// minuteRead is a background read extending the cache's span to from..to.
+// It is dropped if the span it extends changed or minutes it read were
+// invalidated while it ran.
+type minuteRead struct {
+	from, to         int64
+	baseFrom, baseTo int64 // The cache's span when the read started
+	replace          bool  // The span is replaced rather than extended
+	stale            bool  // Minutes it read were invalidated
+	before, after    []OHLCV
+	done             chan struct{}
+}
+
+func newMinuteCache(source MinuteSource) *minuteCache {
+	return &minuteCache{source: source}
+}
+
+// between returns the stored minutes opening in [from, to). The cached span
+// grows to cover the request, or starts over when they don't touch. ok is
+// false while minutes it needs are still being read.
+func (m *minuteCache) between(from, to int64) (bars []OHLCV, ok bool) {
+	from -= from % minuteMs
+	if to <= from {
+		return nil, true
+	}
+	m.poll()
+	if m.to <= m.from || from < m.from || to > m.to {
+		if m.pending == nil {
+			m.start(from, to)
+		}
+		if m.Blocking {
+			<-m.pending.done
+			m.poll()
+		}
+		if m.to <= m.from || from < m.from || to > m.to {
+			return nil, false
+		}
+	}
+
+	i := sort.Search(len(m.bars), func(i int) bool { return m.bars[i].Time >= from })
+	j := sort.Search(len(m.bars), func(i int) bool { return m.bars[i].Time >= to })
+	return m.bars[i:j], true
+}
+
+// start reads the minutes that extend the span to cover [from, to) in the
+// background
+func (m *minuteCache) start(from, to int64) {
+	r := &minuteRead{from: from, to: to, baseFrom: m.from, baseTo: m.to, done: make(chan struct{})}
+	r.replace = m.to <= m.from || to < m.from || from > m.to
+	m.pending = r
+	go func() {
+		defer close(r.done)
+		if r.replace {
+			r.after = m.read(from, to, nil)
+			return
+		}
+		if from < r.baseFrom {
+			r.before = m.read(from, r.baseFrom, nil)
+		}
+		if to > r.baseTo {
+			r.after = m.read(r.baseTo, to, nil)
+		}
+	}()
+}
+
+// poll merges a finished background read into the cache and reports
+// whether one finished, so the profiles redraw and read on if it was dropped
+func (m *minuteCache) poll() bool {
+	r := m.pending
+	if r == nil {
+		return false
+	}
+	select {
+	case <-r.done:
+	default:
+		return false
+	}
+	m.pending = nil
+	switch {
+	case r.stale || !r.replace && (m.from != r.baseFrom || m.to != r.baseTo):
+		// Read again on the next request
+	case r.replace:
+		m.bars, m.from, m.to = r.after, r.from, r.to
+	default:
+		if r.from < m.from {
+			m.bars = append(r.before, m.bars...)
+			m.from = r.from
+		}
+		if r.to > m.to {
+			m.bars = append(m.bars, r.after...)
+			m.to = r.to
+		}
+	}
+	return true
+}
+
+// invalidate forgets the minutes from t on, which may still be filled in
+// or revised
+func (m *minuteCache) invalidate(t int64) {
+	t -= t % minuteMs
+	if r := m.pending; r != nil && t < r.to {
+		r.stale = true
+	}
+	if t >= m.to {
+		return
+	}
+	if t <= m.from {
+		m.bars, m.from, m.to = nil, 0, 0
+		return
+	}
+	m.bars = m.bars[:sort.Search(len(m.bars), func(i int) bool { return m.bars[i].Time >= t })]
+	m.to = t
+}
+
+// read returns bars with the stored minutes in [from, to) appended.
+// Unreadable and missing minutes are left out.
+func (m *minuteCache) read(from, to int64, bars []OHLCV) []OHLCV {
+	for t := from; t < to; t += minuteMs {
+		bar, ok, err := m.source.Minute(t)
+		if err == nil && ok {
+			bars = append(bars, bar)
+		}
+	}
+	return bars
+}
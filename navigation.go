@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// maxHistoryMinutes caps the minutes read to reach a date before the loaded
+// bars
+const maxHistoryMinutes = 500000
+
+// updatePrompt feeds the keyboard to the open go to date prompt and jumps
+// once a date is entered
+func (v *ChartView) updatePrompt() {
+	if !v.prompt.Update() {
+		return
+	}
+	v.needsRedraw = true
+	switch {
+	case v.prompt.closed:
+		v.prompt = nil
+	case v.prompt.submitted:
+		v.prompt.submitted = false
+		t, err := parseDateTime(string(v.prompt.text))
+		if err == nil {
+			err = v.goTo(t)
+		}
+		if err != nil {
+			v.prompt.err = err.Error()
+			return
+		}
+		v.prompt = nil
+	}
+}
+
+// goTo centres the view on the bar containing t and puts the crosshair on
+// it. Bars older than the loaded ones are read from the store first.
+func (v *ChartView) goTo(t time.Time) error {
+	c := v.chart
+	if len(c.Data) == 0 {
+		return fmt.Errorf("no bars loaded")
+	}
+	ms := t.UnixMilli()
+	if first := c.Data[0].Time; ms < first {
+		from := ms - int64(c.pageBars()/2)*c.barInterval()
+		if (first-from)/minuteMs > maxHistoryMinutes {
+			return fmt.Errorf("%s is too far before the loaded bars", t.UTC().Format("2006-01-02"))
+		}
+		if err := v.loadBefore(from); err != nil {
+			return err
+		}
+	}
+
+	idx := max(0, sort.Search(len(c.Data), func(i int) bool { return c.Data[i].Time > ms })-1)
+	c.glide = 0
+	c.scrollTo(float64(idx) - c.pageBars()/2)
+	v.interaction.StepTo(c, idx)
+	return nil
+}
+
+// goToOldest scrolls to the oldest bar in the stores, first loading the
+// bars before the loaded ones, at most maxHistoryMinutes back
+func (v *ChartView) goToOldest() error {
+	c := v.chart
+	if len(c.Data) == 0 {
+		return fmt.Errorf("no bars loaded")
+	}
+	// A synthetic chart starts where all of its symbols have data
+	var oldest int64
+	for _, db := range v.stores {
+		t, ok, err := db.Earliest()
+		if err != nil {
+			return err
+		}
+		if ok {
+			oldest = max(oldest, t)
+		}
+	}
+	if first := c.Data[0].Time; oldest != 0 && oldest < first {
+		if err := v.loadBefore(max(oldest, first-maxHistoryMinutes*minuteMs)); err != nil {
+			return err
+		}
+	}
+	c.scrollBars(-c.position())
+	return nil
+}
+
+// loadBefore reads the bars from from up to the first loaded bar into the
+// chart and its compares
+func (v *ChartView) loadBefore(from int64) error {
+	bars, err := v.timeframe.GetRange(from, v.chart.Data[0].Time)
+	if err != nil {
+		return err
+	}
+	v.chart.MergeData(bars)
+	v.setTickSize()
+	v.loadCompares()
+	return nil
+}
+
+// loadCompares reloads the compare series over the chart's bars
+func (v *ChartView) loadCompares() {
+	for _, cmp := range v.compares {
+		if err := cmp.Load(); err != nil {
+			log.Printf("Failed to load compare bars: %v", err)
+		}
+	}
+	v.chart.viewportChanged()
+}
+
+// handleTouches applies the gesture of the fingers on the view: one or two
+// fingers pan, a pinch zooms around its centre, a long press pins the
+// crosshair under the finger and a tap releases it. It reports whether the
+// view changed.
+func (v *ChartView) handleTouches(now time.Time, touches []touchPoint) bool {
+	v.gestures.Slop = v.layout.Px(v.config.TouchSlop)
+	g := v.gestures.Update(now, touches)
+	c := v.chart
+	changed := true
+	switch {
+	case g.began:
+		c.glide = 0
+		changed = false
+	case g.hold:
+		v.interaction.PinAt(c, g.x)
+	case g.tap:
+		v.interaction.Unpin()
+	case g.released:
+		c.release(now)
+	case g.zoom != 1 || g.panX != 0:
+		c.panTime(g.panX)
+		c.trackDrag(g.panX, now)
+		if g.zoom != 1 {
+			c.zoomAt(g.x, g.zoom)
+		}
+	default:
+		changed = false
+	}
+	return changed
+}
+
+// zoomAnchor returns the x that keyboard zoom keeps in place: the crosshair,
+// else the latest bar while it is in view, else the middle of the chart
+func (v *ChartView) zoomAnchor() float64 {
+	c := v.chart
+	if v.interaction.showCrosshair {
+		return v.interaction.crosshairX
+	}
+	if start, end := c.visibleRange(); start != -1 && end == len(c.Data) {
+		return float64(c.barX(end - 1 - start))
+	}
+	return v.layout.LeftMargin + (v.layout.Width-v.layout.LeftMargin-v.layout.RightMargin)/2
+}
@@ -2,9 +2,10 @@ package main
 
 import (
 	"math"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/vector"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 )
 
 type OHLCV struct {
@@ -25,16 +26,50 @@ type Chart struct {
 	priceMax  float64
 	timeStart int64
 	timeEnd   int64
-	ts_from   int64 // Start timestamp for displayed bars
-	ts_to     int64 // End timestamp for displayed bars
-	config    ChartConfig
+	ts_from   int64   // Start timestamp for displayed bars
+	scroll    float64 // Fraction of a bar scrolled past ts_from, 0 to 1
+	ts_to     int64   // End timestamp for displayed bars
+	interval  int64   // Bar length in milliseconds, 0 infers it from Data
+	Style     SeriesStyle
+	Scale     PriceScale
+	overlays  []Overlay
+	// overlaysRev counts changes to the overlays that the viewport doesn't
+	// show, so their cached layer knows to redraw
+	overlaysRev int
+	// Vertical viewport, follows priceMin/priceMax while autoScale is set
+	viewMin       float64
+	viewMax       float64
+	autoScale     bool
+	dragMode      dragMode
+	dragStartX    int
+	dragStartY    int
+	selection     timeRange // Bars selected along the time axis
+	lastAxisClick time.Time
+	config        ChartConfig
+	layout        *Layout
+	panes         *Panes
+	// Candle and bar geometry, rebuilt when barsKey changes
+	bars    quadBatch
+	barsKey geometryKey
+	// Bars combined per pixel column, rebuilt when lodKey changes
+	lod    []drawBar
+	lodKey lodKey
+	// Kinetic scrolling after a time drag, in pixels per second
+	glide     float64
+	lastDrag  time.Time
+	lastGlide time.Time
 }
 
-func NewChart(config ChartConfig) *Chart {
+func NewChart(config ChartConfig, layout *Layout) *Chart {
 	return &Chart{
-		Zoom:   1.0,
-		config: config,
-		Data:   make([]OHLCV, 0),
+		Zoom:      1.0,
+		Style:     config.SeriesStyle,
+		Scale:     PriceScale{TickSize: config.TickSize},
+		autoScale: true,
+		config:    config,
+		layout:    layout,
+		panes:     NewPanes(layout),
+		Data:      make([]OHLCV, 0),
 	}
 }
 
@@ -45,14 +80,12 @@ func (c *Chart) UpdateData(newData []OHLCV) {
 	}
 
 	c.Data = newData
-	// Recalculate price range for scaling
-	c.priceMin, c.priceMax = calculatePriceRange(c.Data)
 	c.timeStart = c.Data[0].Time
 	c.timeEnd = c.Data[len(c.Data)-1].Time
 
 	// Set ts_from and ts_to to display the last N bars based on visible width
-	visibleWidth := c.config.Width - c.config.LeftMargin - c.config.RightMargin
-	totalBarSpace := c.config.BarWidth + c.config.BarSpacing
+	visibleWidth := c.layout.Width - c.layout.LeftMargin - c.layout.RightMargin
+	totalBarSpace := c.layout.BarWidth + c.layout.BarSpacing
 	maxBars := int(visibleWidth / (totalBarSpace * c.Zoom))
 
 	if len(c.Data) > maxBars {
@@ -60,7 +93,70 @@ func (c *Chart) UpdateData(newData []OHLCV) {
 	} else {
 		c.ts_from = c.Data[0].Time
 	}
+	c.scroll = 0
 	c.ts_to = c.Data[len(c.Data)-1].Time // Last bar is set as ts_to
+	c.viewportChanged()
+}
+
+// MergeData adds bars to the chart, replacing those with the same time,
+// without moving the viewport. A view showing the last bar keeps it in view
+// as new bars arrive. Both Data and bars are in time order.
+func (c *Chart) MergeData(bars []OHLCV) {
+	if len(c.Data) == 0 {
+		c.UpdateData(bars)
+		return
+	}
+	_, end := c.visibleRange()
+	following := end == len(c.Data)
+
+	merged, changed := mergeBars(c.Data, bars)
+	if !changed {
+		return
+	}
+	c.Data = merged
+	c.timeStart = c.Data[0].Time
+	c.timeEnd = c.Data[len(c.Data)-1].Time
+	c.ts_to = c.timeEnd
+
+	if _, end := c.visibleRange(); following && end < len(c.Data) {
+		c.scrollTo(c.position() + float64(len(c.Data)-end))
+		return
+	}
+	c.viewportChanged()
+}
+
+// mergeBars returns the bars of a and b in time order, taking b's bar where
+// both have one. The result is a new slice when anything changed, so caches
+// keyed by the data's first bar see the change.
+func mergeBars(a, b []OHLCV) ([]OHLCV, bool) {
+	merged := make([]OHLCV, 0, len(a)+len(b))
+	changed := false
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i].Time < b[j].Time:
+			merged = append(merged, a[i])
+			i++
+		case a[i].Time > b[j].Time:
+			merged = append(merged, b[j])
+			j++
+			changed = true
+		default:
+			merged = append(merged, b[j])
+			changed = changed || a[i] != b[j]
+			i++
+			j++
+		}
+	}
+	merged = append(merged, a[i:]...)
+	if j < len(b) {
+		merged = append(merged, b[j:]...)
+		changed = true
+	}
+	if !changed {
+		return a, false
+	}
+	return merged, true
 }
 
 func calculatePriceRange(data []OHLCV) (min, max float64) {
@@ -79,130 +175,139 @@ func calculatePriceRange(data []OHLCV) (min, max float64) {
 
 // Update handles zooming and panning interactions
 func (c *Chart) Update() error {
-	// Handle mouse wheel zoom
+	now := time.Now()
+
+	// Zoom around the bar under the cursor
 	_, dy := ebiten.Wheel()
 	if dy != 0 {
-		cx, _ := ebiten.CursorPosition()
-		chartLeft := int(c.config.LeftMargin)
-		chartRight := int(c.config.Width - c.config.RightMargin)
-
-		if cx >= chartLeft && cx <= chartRight {
-			totalBarSpace := c.config.BarWidth + c.config.BarSpacing
-			// Apply zoom
-			newZoom := c.Zoom * math.Pow(1.1, dy)
-			newZoom = math.Max(0.1, math.Min(newZoom, 10.0))
-			c.Zoom = newZoom
-
-			// Recalculate ts_from based on new zoom level
-			visibleWidth := c.config.Width - c.config.LeftMargin - c.config.RightMargin
-			maxBars := int(visibleWidth / (totalBarSpace * c.Zoom))
-			if len(c.Data) > maxBars {
-				c.ts_from = c.Data[len(c.Data)-maxBars].Time
-			} else {
-				c.ts_from = c.Data[0].Time
-			}
-			c.ts_to = c.Data[len(c.Data)-1].Time
+		cx, cy := c.layout.CursorPosition()
+		chartLeft := int(c.layout.LeftMargin)
+		chartRight := int(c.layout.Width - c.layout.RightMargin)
+
+		if cx >= chartLeft && cx <= chartRight && c.panes.At(float64(cy)) != nil {
+			c.glide = 0
+			c.zoomAt(float64(cx), math.Pow(1.1, dy))
 		}
 	}
 
-	// Handle mouse drag panning
+	// Handle mouse drag panning and price-axis scaling
 	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
-		cx, cy := ebiten.CursorPosition()
-		if c.prevX != 0 || c.prevY != 0 {
-			dx := float64(cx - c.prevX)
-			totalBarSpace := c.config.BarWidth + c.config.BarSpacing
-			shiftBars := int(dx / (totalBarSpace * c.Zoom))
-			if shiftBars != 0 {
-				// Adjust ts_from based on drag
-				for i, d := range c.Data {
-					if d.Time == c.ts_from {
-						newIndex := i - shiftBars
-						if newIndex < 0 {
-							newIndex = 0
-						} else if newIndex >= len(c.Data) {
-							newIndex = len(c.Data) - 1
-						}
-						c.ts_from = c.Data[newIndex].Time
-						break
-					}
+		cx, cy := c.layout.CursorPosition()
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			c.dragMode = c.dragModeAt(cx, cy)
+			c.dragStartX, c.dragStartY = cx, cy
+			c.glide = 0
+			if c.dragMode == dragPriceScale {
+				// Double-click on the price axis returns to auto-fit
+				if time.Since(c.lastAxisClick) < doubleClickInterval {
+					c.ResetPriceView()
 				}
+				c.lastAxisClick = time.Now()
+			}
+		}
+		if c.prevX != 0 || c.prevY != 0 {
+			dy := float64(cy - c.prevY)
+			switch {
+			case c.dragMode == dragPriceScale && dy != 0:
+				c.zoomPrice(math.Exp(dy * 0.01))
+			case c.dragMode == dragPricePan && dy != 0:
+				c.panPrice(dy)
+			case c.dragMode == dragTime:
+				c.panTime(float64(cx - c.prevX))
+				c.trackDrag(float64(cx-c.prevX), now)
+			case c.dragMode == dragSelect:
+				c.selectBetween(float64(c.dragStartX), float64(cx))
 			}
 		}
 		c.prevX, c.prevY = cx, cy
 	} else {
+		switch c.dragMode {
+		case dragTime:
+			c.release(now)
+		case dragBoxZoom:
+			c.zoomToBox()
+		case dragSelect:
+			// A click on the time axis without dragging clears the selection
+			if c.prevX == c.dragStartX {
+				c.selection = timeRange{}
+			}
+		}
 		c.prevX, c.prevY = 0, 0
+		c.dragMode = dragNone
+		c.updateGlide(now)
 	}
 
 	return nil
 }
 
-// Draw renders the chart starting from ts_from and draws bars until there's no space
-func (c *Chart) Draw(screen *ebiten.Image) {
-	totalBarSpace := c.config.BarWidth + c.config.BarSpacing
-	//visibleWidth := c.config.Width - c.config.LeftMargin - c.config.RightMargin
+// Draw renders the chart starting from ts_from with its overlays on top
+func (c *Chart) Draw(screen Renderer) {
+	c.DrawSeries(screen)
+	c.DrawOverlays(screen)
+}
+
+// DrawSeries renders the bars from ts_from in the configured series style
+func (c *Chart) DrawSeries(screen Renderer) {
+	start, end := c.visibleRange()
+	if start == -1 {
+		return // No bars to display
+	}
+
+	switch c.Style {
+	case StyleLine:
+		c.drawLine(screen, start, end)
+	case StyleArea:
+		c.drawArea(screen, start, end)
+	case StyleBaseline:
+		c.drawBaseline(screen, start, end)
+	default:
+		c.drawBarGeometry(screen, start, end)
+	}
+}
+
+// DrawOverlays renders the overlays over the series
+func (c *Chart) DrawOverlays(screen Renderer) {
+	for _, o := range c.overlays {
+		o.Draw(screen, c)
+	}
+}
 
-	// Find the index of the first bar with Time >= ts_from
-	startIndex := -1
+// visibleRange returns the index of the first bar at or after ts_from and the
+// index one past the last bar that still fits in the chart area.
+func (c *Chart) visibleRange() (start, end int) {
+	start = -1
 	for i, d := range c.Data {
 		if d.Time >= c.ts_from {
-			startIndex = i
+			start = i
 			break
 		}
 	}
-	if startIndex == -1 {
-		return // No bars to display
+	if start == -1 {
+		return -1, -1
 	}
 
-	// Draw bars starting from startIndex until there's no more space
-	for i := startIndex; i < len(c.Data); i++ {
-		ohlcv := c.Data[i]
-		x := float32(c.config.LeftMargin + float64(i-startIndex)*totalBarSpace*c.Zoom)
+	end = start
+	for end < len(c.Data) && c.barX(end-start) <= float32(c.layout.Width-c.layout.RightMargin) {
+		end++
+	}
+	return start, end
+}
 
-		// Stop drawing if the bar exceeds the visible area
-		if x > float32(c.config.Width-c.config.RightMargin) {
-			break
-		}
+// barX returns the x coordinate of the bar offset bars to the right of
+// ts_from, shifted left by the fraction of a bar scrolled
+func (c *Chart) barX(offset int) float32 {
+	return float32(c.layout.LeftMargin + (float64(offset)-c.scroll)*c.barSpacing())
+}
 
-		barTop := float32(c.config.Height - c.config.BottomMargin - ((ohlcv.High - c.priceMin) / (c.priceMax - c.priceMin) * float64(c.config.Height-c.config.TopMargin-c.config.BottomMargin)))
-		barBottom := float32(c.config.Height - c.config.BottomMargin - ((ohlcv.Low - c.priceMin) / (c.priceMax - c.priceMin) * float64(c.config.Height-c.config.TopMargin-c.config.BottomMargin)))
-
-		// Draw the bar (high to low line)
-		vector.StrokeLine(
-			screen,
-			x, barTop,
-			x, barBottom,
-			float32(c.config.BarWidth),
-			c.config.BarColor,
-			false,
-		)
-
-		openY := float32(c.config.Height - c.config.BottomMargin - ((ohlcv.Open - c.priceMin) / (c.priceMax - c.priceMin) * float64(c.config.Height-c.config.TopMargin-c.config.BottomMargin)))
-		closeY := float32(c.config.Height - c.config.BottomMargin - ((ohlcv.Close - c.priceMin) / (c.priceMax - c.priceMin) * float64(c.config.Height-c.config.TopMargin-c.config.BottomMargin)))
-
-		// Draw open and close ticks
-		vector.StrokeLine(
-			screen,
-			x-2, openY,
-			x+2, openY,
-			1.0,
-			c.config.OpenColor,
-			false,
-		)
-		vector.StrokeLine(
-			screen,
-			x-2, closeY,
-			x+2, closeY,
-			1.0,
-			c.config.CloseColor,
-			false,
-		)
-	}
+// bodyWidth returns the candle body width for the current zoom level
+func (c *Chart) bodyWidth() float32 {
+	return float32(math.Max(1, c.layout.BarSpacing*c.Zoom-c.layout.BodySpacing))
 }
 
 func (c *Chart) GetBarPosition(index int) (left, center, right float64) {
-	totalBarSpace := (c.config.BarWidth + c.config.BarSpacing) * c.Zoom
-	left = c.config.LeftMargin + (float64(index) * totalBarSpace)
-	right = left + c.config.BarWidth*c.Zoom
-	center = left + (c.config.BarWidth*c.Zoom)/2
+	totalBarSpace := (c.layout.BarWidth + c.layout.BarSpacing) * c.Zoom
+	left = c.layout.LeftMargin + (float64(index)-c.scroll)*totalBarSpace
+	right = left + c.layout.BarWidth*c.Zoom
+	center = left + (c.layout.BarWidth*c.Zoom)/2
 	return left, center, right
 }
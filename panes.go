@@ -0,0 +1,285 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// PaneContent is an indicator drawn in its own pane below the price pane
+type PaneContent interface {
+	Name() string
+	// Range reports the value extent over the bars start..end-1
+	Range(chart *Chart, start, end int) (min, max float64, ok bool)
+	Draw(screen Renderer, chart *Chart, pane *Pane)
+	// FormatValue renders an axis label with the precision step needs
+	FormatValue(v, step float64) string
+}
+
+// Pane is a horizontal band of the chart area with its own y-scale
+type Pane struct {
+	Content PaneContent // nil for the main price pane
+	Weight  float64     // Share of the chart height relative to other panes
+	Top     float64
+	Bottom  float64
+	min     float64 // Value range of indicator panes
+	max     float64
+}
+
+// Height returns the pane height in pixels, 0 when hidden by a maximized pane
+func (p *Pane) Height() float64 {
+	return p.Bottom - p.Top
+}
+
+// Contains reports whether y lies inside the pane
+func (p *Pane) Contains(y float64) bool {
+	return p.Height() > 0 && y >= p.Top && y <= p.Bottom
+}
+
+// Rect returns the pane's bounds for clipping
+func (p *Pane) Rect(layout *Layout) image.Rectangle {
+	return image.Rect(
+		int(layout.LeftMargin), int(math.Floor(p.Top)),
+		int(layout.Width-layout.RightMargin), int(math.Ceil(p.Bottom)),
+	)
+}
+
+// valueToY maps an indicator value to its y coordinate within the pane
+func (p *Pane) valueToY(v float64) float32 {
+	if p.max == p.min {
+		return float32(p.Bottom)
+	}
+	return float32(p.Bottom - (v-p.min)/(p.max-p.min)*p.Height())
+}
+
+// yToValue maps a y coordinate within the pane back to an indicator value
+func (p *Pane) yToValue(y float64) float64 {
+	return p.min + (p.Bottom-y)/p.Height()*(p.max-p.min)
+}
+
+// Panes stacks the price pane and indicator panes vertically. They share the
+// time axis, and the splitters between them can be dragged to resize.
+type Panes struct {
+	panes     []*Pane
+	maximized *Pane
+	dragging  int // Index of the splitter above panes[dragging], -1 when idle
+	prevY     int
+	layout    *Layout
+}
+
+// minPaneHeight keeps panes grabbable when a splitter is dragged to the edge
+const minPaneHeight = 20
+
+func NewPanes(layout *Layout) *Panes {
+	p := &Panes{
+		panes:    []*Pane{{Weight: 3}},
+		dragging: -1,
+		layout:   layout,
+	}
+	p.arrange()
+	return p
+}
+
+// Main returns the price pane
+func (p *Panes) Main() *Pane {
+	for _, pane := range p.panes {
+		if pane.Content == nil {
+			return pane
+		}
+	}
+	return p.panes[0]
+}
+
+// All returns the panes from top to bottom
+func (p *Panes) All() []*Pane {
+	return p.panes
+}
+
+// Add appends an indicator pane at the bottom
+func (p *Panes) Add(content PaneContent, weight float64) *Pane {
+	pane := &Pane{Content: content, Weight: weight}
+	p.panes = append(p.panes, pane)
+	p.arrange()
+	return pane
+}
+
+// Remove deletes an indicator pane; the price pane can't be removed
+func (p *Panes) Remove(pane *Pane) {
+	if pane.Content == nil {
+		return
+	}
+	for i, q := range p.panes {
+		if q == pane {
+			p.panes = append(p.panes[:i], p.panes[i+1:]...)
+			break
+		}
+	}
+	if p.maximized == pane {
+		p.maximized = nil
+	}
+	p.arrange()
+}
+
+// Find returns the pane showing content, or nil
+func (p *Panes) Find(content PaneContent) *Pane {
+	for _, pane := range p.panes {
+		if pane.Content == content {
+			return pane
+		}
+	}
+	return nil
+}
+
+// ToggleMaximize gives pane the whole chart area, or restores the split
+func (p *Panes) ToggleMaximize(pane *Pane) {
+	if p.maximized == pane {
+		p.maximized = nil
+	} else {
+		p.maximized = pane
+	}
+	p.arrange()
+}
+
+// Move shifts pane up (delta < 0) or down (delta > 0) in the stack
+func (p *Panes) Move(pane *Pane, delta int) {
+	for i, q := range p.panes {
+		if q != pane {
+			continue
+		}
+		j := i + delta
+		if j < 0 || j >= len(p.panes) {
+			return
+		}
+		p.panes[i], p.panes[j] = p.panes[j], p.panes[i]
+		break
+	}
+	p.arrange()
+}
+
+// At returns the pane under y, or nil
+func (p *Panes) At(y float64) *Pane {
+	for _, pane := range p.panes {
+		if pane.Contains(y) {
+			return pane
+		}
+	}
+	return nil
+}
+
+// Top and Bottom bound the area shared by all panes
+func (p *Panes) Top() float64    { return p.layout.TopMargin }
+func (p *Panes) Bottom() float64 { return p.layout.Height - p.layout.BottomMargin }
+
+// splitterAt returns the index of the pane whose top splitter is at y, or -1
+func (p *Panes) splitterAt(y float64) int {
+	if p.maximized != nil {
+		return -1
+	}
+	grab := p.layout.Px(4)
+	for i := 1; i < len(p.panes); i++ {
+		if math.Abs(y-p.panes[i].Top) <= grab {
+			return i
+		}
+	}
+	return -1
+}
+
+// Dragging reports whether a splitter is being dragged
+func (p *Panes) Dragging() bool {
+	return p.dragging != -1
+}
+
+// Update lays the panes out, fits their scales and handles splitter
+// dragging. It reports whether the arrangement changed.
+func (p *Panes) Update(chart *Chart) bool {
+	cx, cy := p.layout.CursorPosition()
+	p.arrange()
+	p.fitRanges(chart)
+
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) &&
+		float64(cx) >= p.layout.LeftMargin && float64(cx) <= p.layout.Width-p.layout.RightMargin {
+		p.dragging = p.splitterAt(float64(cy))
+		p.prevY = cy
+	}
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		p.dragging = -1
+		return false
+	}
+	if p.dragging == -1 || cy == p.prevY {
+		return false
+	}
+
+	// Move height between the panes either side of the splitter, keeping
+	// the pair's combined weight
+	above, below := p.panes[p.dragging-1], p.panes[p.dragging]
+	total := above.Height() + below.Height()
+	minHeight := p.layout.Px(minPaneHeight)
+	newAbove := math.Max(minHeight, math.Min(float64(cy)-above.Top, total-minHeight))
+	weight := above.Weight + below.Weight
+	above.Weight = weight * newAbove / total
+	below.Weight = weight - above.Weight
+	p.prevY = cy
+	p.arrange()
+	return true
+}
+
+// Hovered returns the pane under the cursor, or nil
+func (p *Panes) Hovered() *Pane {
+	cx, cy := p.layout.CursorPosition()
+	if cx < 0 || float64(cx) >= p.layout.Width {
+		return nil
+	}
+	return p.At(float64(cy))
+}
+
+// arrange splits the chart area between the panes by weight
+func (p *Panes) arrange() {
+	top, bottom := p.Top(), p.Bottom()
+	if p.maximized != nil {
+		for _, pane := range p.panes {
+			pane.Top, pane.Bottom = top, top
+		}
+		p.maximized.Top, p.maximized.Bottom = top, bottom
+		return
+	}
+
+	totalWeight := 0.0
+	for _, pane := range p.panes {
+		totalWeight += pane.Weight
+	}
+	y := top
+	for _, pane := range p.panes {
+		pane.Top = y
+		y += (bottom - top) * pane.Weight / totalWeight
+		pane.Bottom = y
+	}
+}
+
+// fitRanges sets each indicator pane's y-scale to its visible values
+func (p *Panes) fitRanges(chart *Chart) {
+	start, end := chart.visibleRange()
+	if start == -1 || end <= start {
+		return
+	}
+	for _, pane := range p.panes {
+		if pane.Content == nil {
+			continue
+		}
+		if min, max, ok := pane.Content.Range(chart, start, end); ok {
+			pane.min, pane.max = min, max
+		}
+	}
+}
+
+// DrawContent draws each indicator pane clipped to its bounds
+func (p *Panes) DrawContent(screen Renderer, chart *Chart) {
+	for _, pane := range p.panes {
+		if pane.Content == nil || pane.Height() <= 0 {
+			continue
+		}
+		clip := screen.Clip(pane.Rect(p.layout))
+		pane.Content.Draw(clip, chart, pane)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+)
+
+// drawRightAxis draws the right-hand price axis line and its markers: the
+// visible high and low, and the last price with a countdown to the bar close
+func (a *Axes) drawRightAxis(screen Renderer, chart *Chart) {
+	chartRight := a.layout.Width - a.layout.RightMargin
+	screen.StrokeLine(
+		float32(chartRight),
+		float32(a.layout.TopMargin),
+		float32(chartRight),
+		float32(a.layout.Height-a.layout.BottomMargin),
+		a.layout.AxisWidth,
+		a.config.AxisColor,
+	)
+
+	start, end := chart.visibleRange()
+	if start == -1 || end <= start || chart.panes.Main().Height() <= 0 {
+		return
+	}
+
+	// Visible high and low
+	low, high := calculatePriceRange(chart.Data[start:end])
+	a.drawPriceTag(screen, chart, high, "H "+chart.Scale.format(high), a.config.CrosshairBgColor, a.config.LabelColor)
+	a.drawPriceTag(screen, chart, low, "L "+chart.Scale.format(low), a.config.CrosshairBgColor, a.config.LabelColor)
+
+	// Last price with a line across the chart and the time left in the bar
+	last := chart.Data[len(chart.Data)-1]
+	tagColor := a.config.UpColor
+	if last.Close < last.Open {
+		tagColor = a.config.DownColor
+	}
+	y := chart.priceToY(last.Close)
+	if chart.panes.Main().Contains(float64(y)) {
+		dash := float32(a.layout.Px(4))
+		for x := float32(a.layout.LeftMargin); x < float32(chartRight); x += dash * 2 {
+			screen.StrokeLine(x, y, min32(x+dash, float32(chartRight)), y, a.layout.Px32(1), tagColor)
+		}
+	}
+	closeTime := msToTime(last.Time + chart.barInterval())
+	label := chart.Scale.format(last.Close) + "\n" + formatCountdown(time.Until(closeTime))
+	a.drawPriceTag(screen, chart, last.Close, label, tagColor, a.config.LabelColor)
+}
+
+// drawPriceTag draws a filled tag on the right axis at the price's height,
+// clamped to the chart area so off-screen prices stay visible at the edge
+func (a *Axes) drawPriceTag(screen Renderer, chart *Chart, price float64, label string, bg, fg color.RGBA) {
+	padding := a.layout.Px(3)
+	lines := 1
+	for _, r := range label {
+		if r == '\n' {
+			lines++
+		}
+	}
+	height := float64(a.labelHeight*lines) + padding*2
+
+	y := float64(chart.priceToY(price)) - float64(a.labelHeight)/2 - padding
+	pane := chart.panes.Main()
+	y = math.Max(pane.Top, math.Min(y, pane.Bottom-height))
+	x := a.layout.Width - a.layout.RightMargin
+
+	screen.FillRect(float32(x), float32(y), float32(a.layout.RightMargin), float32(height), bg)
+	screen.Text(label, a.fontFace, int(x+padding), int(y+padding)+a.fontFace.Metrics().Ascent.Ceil(), fg)
+}
+
+// formatCountdown renders the time left until a bar closes
+func formatCountdown(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	d = d.Round(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%02d:%02d", m, s)
+}
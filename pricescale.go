@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// ScaleMode selects how prices are mapped onto the vertical axis
+type ScaleMode int
+
+const (
+	ScaleLinear ScaleMode = iota
+	ScaleLog
+	ScalePercent // Percent change from the first visible close
+	ScaleIndexed // First visible close indexed to 100
+	scaleModeCount
+)
+
+func (m ScaleMode) String() string {
+	switch m {
+	case ScaleLog:
+		return "Log"
+	case ScalePercent:
+		return "Percent"
+	case ScaleIndexed:
+		return "Indexed to 100"
+	default:
+		return "Linear"
+	}
+}
+
+// PriceScale converts prices to and from the linear space the axis is laid out in
+type PriceScale struct {
+	Mode     ScaleMode
+	Inverted bool
+	Base     float64 // Reference price for percent and indexed modes
+	TickSize float64 // Smallest price increment of the symbol
+}
+
+// minLogPrice keeps the log transform defined for zero or negative prices
+const minLogPrice = 1e-12
+
+// transform maps a price into the scale's linear display space
+func (s *PriceScale) transform(price float64) float64 {
+	switch s.Mode {
+	case ScaleLog:
+		return math.Log10(math.Max(price, minLogPrice))
+	case ScalePercent:
+		return (price/s.base() - 1) * 100
+	case ScaleIndexed:
+		return price / s.base() * 100
+	default:
+		return price
+	}
+}
+
+// inverse maps a value from display space back to a price
+func (s *PriceScale) inverse(v float64) float64 {
+	switch s.Mode {
+	case ScaleLog:
+		return math.Pow(10, v)
+	case ScalePercent:
+		return s.base() * (1 + v/100)
+	case ScaleIndexed:
+		return s.base() * v / 100
+	default:
+		return v
+	}
+}
+
+func (s *PriceScale) base() float64 {
+	if s.Base == 0 {
+		return 1
+	}
+	return s.Base
+}
+
+// ticks returns the prices at which the axis places labels between min and
+// max, and the label step in the scale's display units
+func (s *PriceScale) ticks(min, max float64, count int) ([]float64, float64) {
+	var ticks []float64
+	if s.Mode == ScaleLog {
+		// Labels stay in price space so they read as round prices
+		step := calculateStep(max-min, count)
+		for price := math.Ceil(min/step) * step; price <= max; price += step {
+			ticks = append(ticks, price)
+		}
+		return ticks, step
+	}
+
+	lo, hi := s.transform(min), s.transform(max)
+	step := calculateStep(hi-lo, count)
+	for v := math.Ceil(lo/step) * step; v <= hi; v += step {
+		ticks = append(ticks, s.inverse(v))
+	}
+	return ticks, step
+}
+
+// format renders a price as it reads on this scale at full precision
+func (s *PriceScale) format(price float64) string {
+	switch s.Mode {
+	case ScalePercent:
+		return fmt.Sprintf("%+.2f%%", s.transform(price))
+	case ScaleIndexed:
+		return fmt.Sprintf("%.2f", s.transform(price))
+	default:
+		return fmt.Sprintf("%.*f", stepDecimals(s.TickSize, s.TickSize), price)
+	}
+}
+
+// label renders an axis price with the precision the label step needs
+func (s *PriceScale) label(price, step float64) string {
+	switch s.Mode {
+	case ScalePercent:
+		return fmt.Sprintf("%+.*f%%", stepDecimals(step, 0), s.transform(price))
+	case ScaleIndexed:
+		return fmt.Sprintf("%.*f", stepDecimals(step, 0), s.transform(price))
+	default:
+		return formatPriceLabel(price, step, s.TickSize)
+	}
+}
+
+// CycleScaleMode switches to the next price scale mode
+func (c *Chart) CycleScaleMode() {
+	c.Scale.Mode = (c.Scale.Mode + 1) % scaleModeCount
+	c.fitPriceRange()
+}
+
+// ToggleInverted flips the price axis upside down
+func (c *Chart) ToggleInverted() {
+	c.Scale.Inverted = !c.Scale.Inverted
+}
+
+// priceToY maps a price to its y coordinate within the price pane
+func (c *Chart) priceToY(price float64) float32 {
+	pane := c.panes.Main()
+	lo, hi := c.Scale.transform(c.viewMin), c.Scale.transform(c.viewMax)
+	frac := (c.Scale.transform(price) - lo) / (hi - lo)
+	if c.Scale.Inverted {
+		frac = 1 - frac
+	}
+	return float32(pane.Bottom - frac*pane.Height())
+}
+
+// yToPrice maps a y coordinate within the price pane back to a price
+func (c *Chart) yToPrice(y float64) float64 {
+	pane := c.panes.Main()
+	frac := (pane.Bottom - y) / pane.Height()
+	if c.Scale.Inverted {
+		frac = 1 - frac
+	}
+	lo, hi := c.Scale.transform(c.viewMin), c.Scale.transform(c.viewMax)
+	return c.Scale.inverse(lo + frac*(hi-lo))
+}
+
+// dragMode is what a left-button drag is doing
+type dragMode int
+
+const (
+	dragNone dragMode = iota
+	dragTime
+	dragPricePan
+	dragPriceScale
+	dragBoxZoom // Box over the price pane to zoom to
+	dragSelect  // Time range selection along the time axis
+)
+
+const doubleClickInterval = 300 * time.Millisecond
+
+// dragModeAt picks the drag action for a press at the given position. A
+// press outside the chart, which other charts in the grid also see, starts
+// no drag.
+func (c *Chart) dragModeAt(x, y int) dragMode {
+	fx, fy := float64(x), float64(y)
+	if fx < 0 || fy < 0 || fx >= c.layout.Width || fy >= c.layout.Height {
+		return dragNone
+	}
+	inPlot := fx >= c.layout.LeftMargin && fx <= c.layout.Width-c.layout.RightMargin
+	if inPlot && fy > c.panes.Bottom() && fy < c.layout.Height {
+		return dragSelect
+	}
+	if fy < c.panes.Top() || fy > c.panes.Bottom() || c.panes.splitterAt(fy) != -1 {
+		return dragNone
+	}
+	inPricePane := c.panes.Main().Contains(fy)
+	if fx < c.layout.LeftMargin {
+		if inPricePane {
+			return dragPriceScale
+		}
+		return dragNone
+	}
+	if fx > c.layout.Width-c.layout.RightMargin {
+		return dragNone
+	}
+	if inPricePane && ebiten.IsKeyPressed(ebiten.KeyShift) {
+		return dragPricePan
+	}
+	if inPricePane && ebiten.IsKeyPressed(ebiten.KeyControl) {
+		return dragBoxZoom
+	}
+	return dragTime
+}
+
+// zoomPrice stretches the vertical viewport around its centre, factor > 1 zooms out
+func (c *Chart) zoomPrice(factor float64) {
+	lo, hi := c.Scale.transform(c.viewMin), c.Scale.transform(c.viewMax)
+	mid, half := (lo+hi)/2, (hi-lo)/2*factor
+	c.viewMin, c.viewMax = c.Scale.inverse(mid-half), c.Scale.inverse(mid+half)
+	c.autoScale = false
+}
+
+// panPrice moves the vertical viewport by dy pixels
+func (c *Chart) panPrice(dy float64) {
+	lo, hi := c.Scale.transform(c.viewMin), c.Scale.transform(c.viewMax)
+	delta := dy / c.panes.Main().Height() * (hi - lo)
+	if c.Scale.Inverted {
+		delta = -delta
+	}
+	c.viewMin, c.viewMax = c.Scale.inverse(lo+delta), c.Scale.inverse(hi+delta)
+	c.autoScale = false
+}
+
+// updateScaleBase sets the percent and indexed reference to the first visible close
+func (c *Chart) updateScaleBase() {
+	start, _ := c.visibleRange()
+	if start == -1 {
+		return
+	}
+	c.Scale.Base = c.Data[start].Close
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"unicode"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"golang.org/x/image/font"
+)
+
+// datePrompt is a box over the chart for typing a date to go to. It takes
+// all keyboard input while open.
+type datePrompt struct {
+	text      []rune
+	err       string // Why the last entry was refused
+	submitted bool   // Enter was pressed
+	closed    bool   // Escape was pressed
+	fonts     *fontCache
+	input     []rune
+}
+
+func newDatePrompt() *datePrompt {
+	return &datePrompt{fonts: newFontCache(13)}
+}
+
+// Update applies this tick's typing and reports whether the prompt changed
+func (p *datePrompt) Update() bool {
+	changed := false
+	p.input = ebiten.AppendInputChars(p.input[:0])
+	for _, r := range p.input {
+		if unicode.IsPrint(r) {
+			p.text = append(p.text, r)
+			changed = true
+		}
+	}
+	backspace := inpututil.IsKeyJustPressed(ebiten.KeyBackspace) || keyRepeated(ebiten.KeyBackspace)
+	if backspace && len(p.text) > 0 {
+		p.text = p.text[:len(p.text)-1]
+		changed = true
+	}
+	switch {
+	case inpututil.IsKeyJustPressed(ebiten.KeyEnter) || inpututil.IsKeyJustPressed(ebiten.KeyNumpadEnter):
+		p.submitted = true
+	case inpututil.IsKeyJustPressed(ebiten.KeyEscape):
+		p.closed = true
+	default:
+		if changed {
+			p.err = ""
+		}
+		return changed
+	}
+	return true
+}
+
+// Draw renders the box across the top of the chart area, with the reason
+// the last entry was refused or a hint of the format below the text
+func (p *datePrompt) Draw(screen Renderer, layout *Layout, config ChartConfig) {
+	face := p.fonts.get(layout)
+	padding := layout.Px32(8)
+	line := float32(p.fonts.height) * 1.5
+
+	text := "Go to date: " + string(p.text) + "_"
+	note, noteColor := "YYYY-MM-DD [HH:MM] UTC, Enter to go, Esc to cancel", config.LabelColor
+	if p.err != "" {
+		note, noteColor = p.err, config.DownColor
+	}
+	width := float32(max(font.MeasureString(face, text).Ceil(), font.MeasureString(face, note).Ceil())) + 2*padding
+	height := 2*line + 2*padding
+	left := float32(layout.LeftMargin+(layout.Width-layout.LeftMargin-layout.RightMargin)/2) - width/2
+	top := float32(layout.TopMargin) + layout.Px32(10)
+
+	screen.FillRect(left, top, width, height, config.CrosshairBgColor)
+	screen.StrokeRect(left, top, width, height, layout.Px32(1), config.AxisColor)
+	baseline := top + padding + float32(p.fonts.height)
+	screen.Text(text, face, int(left+padding), int(baseline), config.CrosshairTextColor)
+	screen.Text(note, face, int(left+padding), int(baseline+line), noteColor)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runRender implements the render command, which draws a chart of a stored
+// range to a PNG or SVG file without opening a window:
+//
+//	chart render -symbol BTCUSDT -interval 1h -from 2024-01-01 -to 2024-02-01 -o btc.png
+func runRender(args []string) error {
+	config := DefaultConfig
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	symbol := flags.String("symbol", DefaultCharts[0].Symbol, "stored symbol, synthetic name or expression")
+	interval := flags.Duration("interval", time.Hour, "bar length")
+	from := flags.String("from", "", "range start, RFC 3339 or YYYY-MM-DD (default 200 bars before -to)")
+	to := flags.String("to", "", "range end, RFC 3339 or YYYY-MM-DD (default now)")
+	output := flags.String("o", "chart.png", "output file, .png or .svg")
+	width := flags.Int("width", int(config.Width), "image width in pixels")
+	height := flags.Int("height", int(config.Height), "image height in pixels")
+	theme := flags.String("theme", config.Theme, "theme name")
+	tick := flags.Float64("tick", 0, "price increment of the symbol (default inferred from the bars)")
+	compare := flags.String("compare", "", "comma-separated symbols to compare against")
+	profile := flags.String("profile", ProfileNone.String(), "market profile over the price pane: none, volume or tpo")
+	fetch := flags.Bool("fetch", false, "fetch the latest minutes before rendering")
+	flags.Parse(args)
+
+	end := time.Now().UTC()
+	if *to != "" {
+		t, err := parseDateTime(*to)
+		if err != nil {
+			return err
+		}
+		end = t
+	}
+	start := end.Add(-200 * *interval)
+	if *from != "" {
+		t, err := parseDateTime(*from)
+		if err != nil {
+			return err
+		}
+		start = t
+	}
+	if !start.Before(end) {
+		return fmt.Errorf("-from %s is not before -to %s", start, end)
+	}
+
+	chartTheme := startTheme(config.ThemeDir, *theme)
+	chartTheme.Apply(&config)
+	kind, err := parseProfileKind(*profile)
+	if err != nil {
+		return err
+	}
+	config.Profile = kind
+	config.Width, config.Height = float64(*width), float64(*height)
+	game := &Game{config: config, databases: make(map[string]*Database), offline: true}
+	defer game.Close()
+
+	spec := ChartSpec{Symbol: *symbol, Interval: *interval, From: start, To: end, TickSize: *tick}
+	if *compare != "" {
+		spec.Compare = strings.Split(*compare, ",")
+	}
+	if *fetch {
+		for _, symbol := range append([]string{spec.Symbol}, spec.Compare...) {
+			_, stores, err := game.source(symbol)
+			if err != nil {
+				return err
+			}
+			refreshStores(stores)
+		}
+	}
+	game.specs, game.gridSize = []ChartSpec{spec}, 1
+	if err := game.ensureViews(); err != nil {
+		return err
+	}
+	v := game.views[0]
+	if len(v.chart.Data) == 0 {
+		return fmt.Errorf("no %s data between %s and %s", spec.Symbol, start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	if v.minutes != nil {
+		v.minutes.Blocking = true // Draw the profile in the one frame rendered
+	}
+	v.Place(0, 0, float64(*width), float64(*height), 1)
+	v.fitAll()
+
+	switch ext := strings.ToLower(filepath.Ext(*output)); ext {
+	case ".png":
+		r := newImageRenderer(*width, *height)
+		v.render(r)
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(f, r.Image()); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	case ".svg":
+		r := newSVGRenderer(*width, *height)
+		v.render(r)
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		if _, err := r.WriteTo(f); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	default:
+		return fmt.Errorf("unsupported output format %q, use .png or .svg", ext)
+	}
+}
+
+// parseDateTime reads an RFC 3339 timestamp, or a UTC date with an optional
+// time of day
+func parseDateTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use YYYY-MM-DD [HH:MM] or RFC 3339", s)
+}
+
+// fitAll zooms the view so every loaded bar fits and lays it out for
+// drawing without the game loop
+func (v *ChartView) fitAll() {
+	c := v.chart
+	visibleWidth := c.layout.Width - c.layout.LeftMargin - c.layout.RightMargin
+	c.Zoom = visibleWidth / (float64(len(c.Data)) * (c.layout.BarWidth + c.layout.BarSpacing))
+	c.ts_from, c.ts_to, c.scroll = c.Data[0].Time, c.Data[len(c.Data)-1].Time, 0
+	c.viewportChanged()
+	c.panes.arrange()
+	c.panes.fitRanges(c)
+	if v.volumePlacement == VolumeOverlay {
+		v.volume.fitOverlay(c)
+	}
+	v.axes.Update(c)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	"golang.org/x/image/font"
+)
+
+// Renderer is the surface the chart components draw on. The Ebiten backend
+// draws to the window; the image and SVG backends render files headless.
+type Renderer interface {
+	Bounds() image.Rectangle
+	Fill(clr color.Color)
+	FillRect(x, y, width, height float32, clr color.Color)
+	StrokeLine(x0, y0, x1, y1, width float32, clr color.Color)
+	StrokeRect(x, y, width, height, strokeWidth float32, clr color.Color)
+	StrokePath(path *Path, width float32, clr color.Color)
+	FillPath(path *Path, clr color.Color)
+	DrawBatch(batch *quadBatch)
+	// Text draws s with its baseline starting at x, y
+	Text(s string, face font.Face, x, y int, clr color.Color)
+	// Clip returns a renderer that only draws inside rect, in the same
+	// coordinates
+	Clip(rect image.Rectangle) Renderer
+}
+
+// Path is a set of polylines for renderers to stroke or fill
+type Path struct {
+	subpaths [][]pathPoint
+}
+
+type pathPoint struct {
+	x, y float32
+}
+
+// MoveTo starts a new polyline at x, y
+func (p *Path) MoveTo(x, y float32) {
+	p.subpaths = append(p.subpaths, []pathPoint{{x, y}})
+}
+
+// LineTo extends the current polyline to x, y
+func (p *Path) LineTo(x, y float32) {
+	if len(p.subpaths) == 0 {
+		p.MoveTo(x, y)
+		return
+	}
+	last := &p.subpaths[len(p.subpaths)-1]
+	*last = append(*last, pathPoint{x, y})
+}
+
+// Close joins the current polyline back to its start
+func (p *Path) Close() {
+	if len(p.subpaths) == 0 {
+		return
+	}
+	last := &p.subpaths[len(p.subpaths)-1]
+	*last = append(*last, (*last)[0])
+}
+
+// vectorPath converts the path for Ebiten's vector package
+func (p *Path) vectorPath() *vector.Path {
+	var path vector.Path
+	for _, sub := range p.subpaths {
+		appendPolyline(&path, sub)
+	}
+	return &path
+}
+
+// appendPolyline adds points to path as a new polyline
+func appendPolyline(path *vector.Path, points []pathPoint) {
+	for i, pt := range points {
+		if i == 0 {
+			path.MoveTo(pt.x, pt.y)
+		} else {
+			path.LineTo(pt.x, pt.y)
+		}
+	}
+}
+
+// ebitenRenderer draws to an Ebiten image
+type ebitenRenderer struct {
+	dst *ebiten.Image
+}
+
+func newEbitenRenderer(dst *ebiten.Image) Renderer {
+	return ebitenRenderer{dst: dst}
+}
+
+func (r ebitenRenderer) Bounds() image.Rectangle {
+	return r.dst.Bounds()
+}
+
+func (r ebitenRenderer) Fill(clr color.Color) {
+	r.dst.Fill(clr)
+}
+
+func (r ebitenRenderer) FillRect(x, y, width, height float32, clr color.Color) {
+	vector.DrawFilledRect(r.dst, x, y, width, height, clr, false)
+}
+
+func (r ebitenRenderer) StrokeLine(x0, y0, x1, y1, width float32, clr color.Color) {
+	vector.StrokeLine(r.dst, x0, y0, x1, y1, width, clr, false)
+}
+
+func (r ebitenRenderer) StrokeRect(x, y, width, height, strokeWidth float32, clr color.Color) {
+	vector.StrokeRect(r.dst, x, y, width, height, strokeWidth, clr, false)
+}
+
+func (r ebitenRenderer) StrokePath(path *Path, width float32, clr color.Color) {
+	op := &vector.StrokeOptions{Width: width, LineJoin: vector.LineJoinRound}
+	for _, sub := range path.subpaths {
+		r.strokePolyline(sub, op, clr)
+	}
+}
+
+// strokePiecePoints is how many points of a polyline are stroked at a time
+const strokePiecePoints = 4096
+
+// maxStrokeVertices is the most vertices 16-bit indices can address
+const maxStrokeVertices = 1 << 16
+
+// strokePolyline strokes points in pieces whose vertices fit one
+// DrawTriangles call. Round joins add a varying number of vertices per
+// point, so a piece that still needs too many is halved. Neighbouring pieces
+// share a segment so the joins between them are kept; a translucent line
+// shows that segment drawn twice.
+func (r ebitenRenderer) strokePolyline(points []pathPoint, op *vector.StrokeOptions, clr color.Color) {
+	var vs []ebiten.Vertex
+	var is []uint16
+	for len(points) >= 2 {
+		n := min(len(points), strokePiecePoints)
+		for {
+			var path vector.Path
+			appendPolyline(&path, points[:n])
+			vs, is = path.AppendVerticesAndIndicesForStroke(vs[:0], is[:0], op)
+			if len(vs) <= maxStrokeVertices || n <= 3 {
+				break
+			}
+			n = n/2 + 1
+		}
+		drawVertices(r.dst, vs, is, clr, ebiten.FillRuleFillAll)
+		if n == len(points) {
+			return
+		}
+		points = points[n-2:]
+	}
+}
+
+func (r ebitenRenderer) FillPath(path *Path, clr color.Color) {
+	vs, is := path.vectorPath().AppendVerticesAndIndicesForFilling(nil, nil)
+	drawVertices(r.dst, vs, is, clr, ebiten.FillRuleNonZero)
+}
+
+func (r ebitenRenderer) DrawBatch(batch *quadBatch) {
+	batch.draw(r.dst)
+}
+
+func (r ebitenRenderer) Text(s string, face font.Face, x, y int, clr color.Color) {
+	text.Draw(r.dst, s, face, x, y, clr)
+}
+
+func (r ebitenRenderer) Clip(rect image.Rectangle) Renderer {
+	return ebitenRenderer{dst: r.dst.SubImage(rect).(*ebiten.Image)}
+}
+
+// whiteImage is the source texture for filled and stroked paths
+var whiteImage = func() *ebiten.Image {
+	img := ebiten.NewImage(3, 3)
+	img.Fill(color.White)
+	return img
+}()
+
+func drawVertices(dst *ebiten.Image, vs []ebiten.Vertex, is []uint16, clr color.Color, fillRule ebiten.FillRule) {
+	r, g, b, a := clr.RGBA()
+	for i := range vs {
+		vs[i].SrcX = 1
+		vs[i].SrcY = 1
+		vs[i].ColorR = float32(r) / 0xffff
+		vs[i].ColorG = float32(g) / 0xffff
+		vs[i].ColorB = float32(b) / 0xffff
+		vs[i].ColorA = float32(a) / 0xffff
+	}
+
+	op := &ebiten.DrawTrianglesOptions{}
+	op.ColorScaleMode = ebiten.ColorScaleModePremultipliedAlpha
+	op.FillRule = fillRule
+	op.AntiAlias = true
+	dst.DrawTriangles(vs, is, whiteImage.SubImage(whiteImage.Bounds().Inset(1)).(*ebiten.Image), op)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// minGlideSpeed is the kinetic scroll speed, in pixels per second, below
+// which the chart comes to rest
+const minGlideSpeed = 10
+
+// maxGlidePause is how long the pointer may rest before release for the
+// drag to still carry on gliding
+const maxGlidePause = 80 * time.Millisecond
+
+// barSpacing returns the distance between neighbouring bars in pixels
+func (c *Chart) barSpacing() float64 {
+	return (c.layout.BarWidth + c.layout.BarSpacing) * c.Zoom
+}
+
+// position returns the left edge of the view in bars from the first bar:
+// the index of the bar at ts_from plus the fraction scrolled past it
+func (c *Chart) position() float64 {
+	start, _ := c.visibleRange()
+	if start == -1 {
+		return 0
+	}
+	return float64(start) + c.scroll
+}
+
+// scrollTo moves the left edge of the view to pos bars from the first bar,
+// keeping at least the last bar on screen
+func (c *Chart) scrollTo(pos float64) {
+	if len(c.Data) == 0 {
+		return
+	}
+	pos = math.Max(0, math.Min(pos, float64(len(c.Data)-1)))
+	i := int(pos)
+	c.ts_from, c.scroll = c.Data[i].Time, pos-float64(i)
+	c.viewportChanged()
+}
+
+// pageBars returns how many bars fit across the chart
+func (c *Chart) pageBars() float64 {
+	return (c.layout.Width - c.layout.LeftMargin - c.layout.RightMargin) / c.barSpacing()
+}
+
+// scrollBars moves the view n bars later in time, stopping any glide
+func (c *Chart) scrollBars(n float64) {
+	c.glide = 0
+	c.scrollTo(c.position() + n)
+}
+
+// scrollToLatest moves the view so the last bar sits at the right edge
+func (c *Chart) scrollToLatest() {
+	c.glide = 0
+	c.scrollTo(float64(len(c.Data)) - c.pageBars())
+}
+
+// panTime scrolls the bars by dx pixels, to the right when dx is positive
+func (c *Chart) panTime(dx float64) {
+	c.scrollTo(c.position() - dx/c.barSpacing())
+}
+
+// zoomAt scales the bar spacing by factor, keeping the point of the time
+// axis under x in place
+func (c *Chart) zoomAt(x, factor float64) {
+	offset := x - c.layout.LeftMargin
+	anchor := c.position() + offset/c.barSpacing()
+	c.Zoom = c.clampZoom(c.Zoom * factor)
+	c.scrollTo(anchor - offset/c.barSpacing())
+}
+
+// clampZoom limits zoom to between a tenth of the default bar spacing, or
+// every loaded bar across the chart when that is narrower, and ten times it.
+// Zoomed out that far many bars share a pixel column and are drawn combined.
+func (c *Chart) clampZoom(zoom float64) float64 {
+	lo := 0.1
+	if len(c.Data) > 0 {
+		width := c.layout.Width - c.layout.LeftMargin - c.layout.RightMargin
+		lo = math.Min(lo, width/(float64(len(c.Data))*(c.layout.BarWidth+c.layout.BarSpacing)))
+	}
+	return math.Max(lo, math.Min(zoom, 10.0))
+}
+
+// trackDrag records the speed of a time drag moving dx pixels since the
+// last frame, smoothed so one uneven frame doesn't set the glide
+func (c *Chart) trackDrag(dx float64, now time.Time) {
+	if dx == 0 {
+		return
+	}
+	if dt := now.Sub(c.lastDrag).Seconds(); dt > 0 && dt < maxGlidePause.Seconds() {
+		c.glide = 0.7*c.glide + 0.3*dx/dt
+	} else {
+		c.glide = 0
+	}
+	c.lastDrag = now
+}
+
+// release starts the kinetic glide of a time drag, if enabled and the
+// pointer was still moving when let go
+func (c *Chart) release(now time.Time) {
+	if !c.config.KineticScrolling || now.Sub(c.lastDrag) > maxGlidePause || math.Abs(c.glide) < minGlideSpeed {
+		c.glide = 0
+		return
+	}
+	c.lastGlide = now
+}
+
+// updateGlide advances the kinetic glide to now, slowing it by the
+// configured friction until it stops or reaches either end of the data
+func (c *Chart) updateGlide(now time.Time) {
+	if c.glide == 0 {
+		return
+	}
+	dt := now.Sub(c.lastGlide).Seconds()
+	c.lastGlide = now
+	c.glide *= math.Pow(math.Max(0, 1-c.config.KineticFriction), dt)
+	before := c.position()
+	c.panTime(c.glide * dt)
+	if math.Abs(c.glide) < minGlideSpeed || c.position() == before {
+		c.glide = 0
+	}
+}
+
+// Gliding reports whether the chart is still scrolling after a drag
+func (c *Chart) Gliding() bool {
+	return c.glide != 0
+}
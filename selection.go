@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/image/font"
+)
+
+// timeRange is the open times of the first and last bar of a selection.
+// The zero range selects nothing.
+type timeRange struct {
+	from, to int64
+}
+
+func (r timeRange) empty() bool {
+	return r.from == 0 && r.to == 0
+}
+
+// selectBetween selects the bars from the one under x0 to the one under x1
+func (c *Chart) selectBetween(x0, x1 float64) {
+	if len(c.Data) == 0 {
+		return
+	}
+	i, j := c.barIndexAt(x0), c.barIndexAt(x1)
+	if j < i {
+		i, j = j, i
+	}
+	c.selection = timeRange{c.Data[i].Time, c.Data[j].Time}
+}
+
+// selectedBars returns the bars in the selection
+func (c *Chart) selectedBars() []OHLCV {
+	if c.selection.empty() {
+		return nil
+	}
+	i := sort.Search(len(c.Data), func(i int) bool { return c.Data[i].Time >= c.selection.from })
+	j := sort.Search(len(c.Data), func(i int) bool { return c.Data[i].Time > c.selection.to })
+	return c.Data[i:j]
+}
+
+// boxRect returns the box zoom being dragged, clamped to the price pane
+func (c *Chart) boxRect() (left, top, right, bottom float64) {
+	pane := c.panes.Main()
+	clampX := func(x int) float64 {
+		return math.Max(c.layout.LeftMargin, math.Min(float64(x), c.layout.Width-c.layout.RightMargin))
+	}
+	clampY := func(y int) float64 {
+		return math.Max(pane.Top, math.Min(float64(y), pane.Bottom))
+	}
+	x0, x1 := clampX(c.dragStartX), clampX(c.prevX)
+	y0, y1 := clampY(c.dragStartY), clampY(c.prevY)
+	return math.Min(x0, x1), math.Min(y0, y1), math.Max(x0, x1), math.Max(y0, y1)
+}
+
+// zoomToBox fits the view to the time and price range of the box dragged
+// over the price pane, fixing the price range until it is reset
+func (c *Chart) zoomToBox() {
+	left, top, right, bottom := c.boxRect()
+	if right-left < c.layout.Px(4) || bottom-top < c.layout.Px(4) {
+		return
+	}
+	from := c.position() + (left-c.layout.LeftMargin)/c.barSpacing()
+	lo, hi := c.yToPrice(bottom), c.yToPrice(top)
+	width := c.layout.Width - c.layout.LeftMargin - c.layout.RightMargin
+
+	c.glide = 0
+	c.Zoom = c.clampZoom(c.Zoom * width / (right - left))
+	c.scrollTo(from)
+	c.viewMin, c.viewMax = math.Min(lo, hi), math.Max(lo, hi)
+	c.autoScale = false
+}
+
+// selectionStats summarises a run of bars
+type selectionStats struct {
+	change    float64 // Last close less first open
+	percent   float64
+	high, low float64
+	volume    float64
+	bars      int
+	duration  time.Duration // From the first bar's open to the last bar's close
+}
+
+func summarize(bars []OHLCV, intervalMs int64) selectionStats {
+	first, last := bars[0], bars[len(bars)-1]
+	s := selectionStats{change: last.Close - first.Open, bars: len(bars)}
+	if first.Open != 0 {
+		s.percent = s.change / first.Open * 100
+	}
+	s.low, s.high = calculatePriceRange(bars)
+	for _, b := range bars {
+		s.volume += b.Volume
+	}
+	s.duration = time.Duration(last.Time+intervalMs-first.Time) * time.Millisecond
+	return s
+}
+
+// formatSpan renders a duration in its two largest units
+func formatSpan(d time.Duration) string {
+	days, h, m := int(d.Hours())/24, int(d.Hours())%24, int(d.Minutes())%60
+	switch {
+	case days > 0 && h > 0:
+		return fmt.Sprintf("%dd %dh", days, h)
+	case days > 0:
+		return fmt.Sprintf("%dd", days)
+	case h > 0 && m > 0:
+		return fmt.Sprintf("%dh %dm", h, m)
+	case h > 0:
+		return fmt.Sprintf("%dh", h)
+	default:
+		return fmt.Sprintf("%dm", m)
+	}
+}
+
+// writeBarsCSV writes bars as CSV with a header row, times in RFC 3339 UTC
+func writeBarsCSV(w io.Writer, bars []OHLCV) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"time", "open", "high", "low", "close", "volume"})
+	price := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, b := range bars {
+		out.Write([]string{
+			msToTime(b.Time).UTC().Format(time.RFC3339),
+			price(b.Open), price(b.High), price(b.Low), price(b.Close), price(b.Volume),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// exportFileName names the CSV file for bars of symbol, keeping only
+// characters safe in file names
+func exportFileName(symbol string, interval time.Duration, bars []OHLCV) string {
+	safe := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, symbol)
+	stamp := func(t int64) string { return msToTime(t).UTC().Format("20060102T1504") }
+	span := strings.ReplaceAll(formatSpan(interval), " ", "")
+	return fmt.Sprintf("%s_%s_%s_%s.csv", safe, span, stamp(bars[0].Time), stamp(bars[len(bars)-1].Time))
+}
+
+// exportSelection writes the selected bars to a CSV file in the export
+// directory and returns its path
+func (v *ChartView) exportSelection() (string, error) {
+	bars := v.chart.selectedBars()
+	if len(bars) == 0 {
+		return "", fmt.Errorf("no bars selected")
+	}
+	path := filepath.Join(v.config.ExportDir, exportFileName(v.spec.Symbol, v.timeframe.Interval, bars))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if err := writeBarsCSV(f, bars); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// SelectionView draws the box of a box zoom while it is dragged, and the
+// time-range selection with a summary of its bars
+type SelectionView struct {
+	status    string    // Outcome of the last export
+	statusFor timeRange // Selection the status is about
+	fonts     *fontCache
+	config    ChartConfig
+	layout    *Layout
+}
+
+func NewSelectionView(config ChartConfig, layout *Layout) *SelectionView {
+	return &SelectionView{fonts: newFontCache(12), config: config, layout: layout}
+}
+
+// SetStatus shows msg under the summary of the current selection
+func (s *SelectionView) SetStatus(chart *Chart, msg string) {
+	s.status, s.statusFor = msg, chart.selection
+}
+
+func (s *SelectionView) Draw(screen Renderer, chart *Chart) {
+	if chart.dragMode == dragBoxZoom {
+		left, top, right, bottom := chart.boxRect()
+		w, h := float32(right-left), float32(bottom-top)
+		screen.FillRect(float32(left), float32(top), w, h, s.config.SelectionColor)
+		screen.StrokeRect(float32(left), float32(top), w, h, s.layout.Px32(1), s.config.CrosshairColor)
+	}
+
+	bars := chart.selectedBars()
+	if len(bars) == 0 {
+		return
+	}
+	half := chart.barSpacing() / 2
+	x0 := math.Max(s.layout.LeftMargin, chart.timeToX(bars[0].Time)-half)
+	x1 := math.Min(s.layout.Width-s.layout.RightMargin, chart.timeToX(bars[len(bars)-1].Time)+half)
+	if x1 > x0 {
+		top, bottom := chart.panes.Top(), chart.panes.Bottom()
+		screen.FillRect(float32(x0), float32(top), float32(x1-x0), float32(bottom-top), s.config.SelectionColor)
+	}
+	s.drawSummary(screen, chart, summarize(bars, chart.barInterval()))
+}
+
+// drawSummary lists the selection's statistics in a box at the top right
+// of the price pane
+func (s *SelectionView) drawSummary(screen Renderer, chart *Chart, stats selectionStats) {
+	decimals := stepDecimals(chart.Scale.TickSize, chart.Scale.TickSize)
+	rows := [][2]string{
+		{"Change", fmt.Sprintf("%+.*f (%+.2f%%)", decimals, stats.change, stats.percent)},
+		{"High", fmt.Sprintf("%.*f", decimals, stats.high)},
+		{"Low", fmt.Sprintf("%.*f", decimals, stats.low)},
+		{"Volume", formatVolume(stats.volume, calculateStep(stats.volume, 100))},
+		{"Bars", strconv.Itoa(stats.bars)},
+		{"Span", formatSpan(stats.duration)},
+	}
+	status := ""
+	if s.statusFor == chart.selection {
+		status = s.status
+	}
+
+	face := s.fonts.get(s.layout)
+	padding := s.layout.Px32(6)
+	lineHeight := float32(s.fonts.height) + s.layout.Px32(3)
+	labelWidth, valueWidth := 0, 0
+	for _, row := range rows {
+		labelWidth = max(labelWidth, font.MeasureString(face, row[0]).Ceil())
+		valueWidth = max(valueWidth, font.MeasureString(face, row[1]).Ceil())
+	}
+	gap := s.layout.Px32(10)
+	boxWidth := float32(labelWidth+valueWidth) + gap + 2*padding
+	lines := len(rows)
+	if status != "" {
+		boxWidth = max32(boxWidth, float32(font.MeasureString(face, status).Ceil())+2*padding)
+		lines++
+	}
+	boxHeight := float32(lines)*lineHeight + 2*padding
+	left := float32(s.layout.Width-s.layout.RightMargin) - boxWidth - s.layout.Px32(8)
+	top := float32(chart.panes.Main().Top) + s.layout.Px32(6)
+
+	screen.FillRect(left, top, boxWidth, boxHeight, s.config.CrosshairBgColor)
+	screen.StrokeRect(left, top, boxWidth, boxHeight, s.layout.Px32(1), s.config.AxisColor)
+	baseline := func(line int) int {
+		return int(top + padding + float32(line)*lineHeight + float32(s.fonts.height))
+	}
+	for i, row := range rows {
+		clr := s.config.CrosshairTextColor
+		if i == 0 {
+			clr = s.config.UpColor
+			if stats.change < 0 {
+				clr = s.config.DownColor
+			}
+		}
+		screen.Text(row[0], face, int(left+padding), baseline(i), s.config.LabelColor)
+		screen.Text(row[1], face, int(left+padding+float32(labelWidth)+gap), baseline(i), clr)
+	}
+	if status != "" {
+		screen.Text(status, face, int(left+padding), baseline(len(rows)), s.config.LabelColor)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"image/color"
+)
+
+// SeriesStyle selects how the price series is drawn
+type SeriesStyle int
+
+const (
+	StyleCandles SeriesStyle = iota
+	StyleHollowCandles
+	StyleLine
+	StyleArea
+	StyleBaseline
+	StyleHLC
+	StyleOHLC
+	seriesStyleCount
+)
+
+func (s SeriesStyle) String() string {
+	switch s {
+	case StyleCandles:
+		return "Candles"
+	case StyleHollowCandles:
+		return "Hollow candles"
+	case StyleLine:
+		return "Line"
+	case StyleArea:
+		return "Area"
+	case StyleBaseline:
+		return "Baseline"
+	case StyleHLC:
+		return "HLC bars"
+	default:
+		return "OHLC bars"
+	}
+}
+
+// CycleStyle switches the chart to the next series style
+func (c *Chart) CycleStyle() {
+	c.Style = (c.Style + 1) % seriesStyleCount
+}
+
+// barColors returns the body and wick colours for a bar
+func (c *Chart) barColors(d OHLCV) (body, wick color.RGBA) {
+	if d.Close >= d.Open {
+		return c.config.UpColor, c.config.UpWickColor
+	}
+	return c.config.DownColor, c.config.DownWickColor
+}
+
+// drawBarGeometry draws the candle and bar styles from the chart's batch,
+// rebuilding it only when the data or the viewport has changed
+func (c *Chart) drawBarGeometry(screen Renderer, start, end int) {
+	pane := c.panes.Main()
+	key := c.geometryKey(start, end, pane.Top, pane.Bottom, c.viewMin, c.viewMax)
+	if key != c.barsKey {
+		c.bars.reset()
+		switch c.Style {
+		case StyleCandles:
+			c.appendCandles(&c.bars, start, end, false)
+		case StyleHollowCandles:
+			c.appendCandles(&c.bars, start, end, true)
+		case StyleHLC:
+			c.appendBars(&c.bars, start, end, false)
+		default:
+			c.appendBars(&c.bars, start, end, true)
+		}
+		c.barsKey = key
+	}
+	screen.DrawBatch(&c.bars)
+}
+
+// appendCandles adds filled candlesticks to the batch, or hollow candles where
+// rising bodies are outlined and the colour follows the change from the
+// previous close
+func (c *Chart) appendCandles(batch *quadBatch, start, end int, hollow bool) {
+	bodyWidth := c.bodyWidth()
+	wickWidth := float32(c.layout.BarWidth)
+	outline := c.layout.Px32(1)
+	bars := c.visibleBars(start, end)
+	for k, d := range bars {
+		x := d.x
+
+		body, wick := c.barColors(d.OHLCV)
+		if hollow && (k > 0 || start > 0) {
+			prevClose := c.Data[max(start-1, 0)].Close
+			if k > 0 {
+				prevClose = bars[k-1].Close
+			}
+			if d.Close >= prevClose {
+				body, wick = c.config.UpColor, c.config.UpWickColor
+			} else {
+				body, wick = c.config.DownColor, c.config.DownWickColor
+			}
+		}
+
+		highY, lowY := c.priceToY(d.High), c.priceToY(d.Low)
+		bodyLowY, bodyHighY := c.priceToY(d.bodyLow), c.priceToY(d.bodyHigh)
+		bodyTop, bodyBottom := min32(bodyLowY, bodyHighY), max32(bodyLowY, bodyHighY)
+		if bodyBottom-bodyTop < 1 {
+			bodyBottom = bodyTop + 1
+		}
+
+		left := x - bodyWidth/2
+		if hollow && d.Close >= d.Open {
+			// Wick stops at the body so the hollow stays empty
+			batch.addLine(x, highY, x, bodyTop, wickWidth, wick)
+			batch.addLine(x, bodyBottom, x, lowY, wickWidth, wick)
+			batch.addLine(left, bodyTop, left+bodyWidth, bodyTop, outline, body)
+			batch.addLine(left, bodyBottom, left+bodyWidth, bodyBottom, outline, body)
+			batch.addLine(left, bodyTop, left, bodyBottom, outline, body)
+			batch.addLine(left+bodyWidth, bodyTop, left+bodyWidth, bodyBottom, outline, body)
+			continue
+		}
+
+		batch.addLine(x, highY, x, lowY, wickWidth, wick)
+		batch.addRect(left, bodyTop, bodyWidth, bodyBottom-bodyTop, body)
+	}
+}
+
+// appendBars adds high-low sticks with a close tick to the batch, plus an
+// open tick for OHLC
+func (c *Chart) appendBars(batch *quadBatch, start, end int, withOpen bool) {
+	tick := c.bodyWidth() / 2
+	for _, d := range c.visibleBars(start, end) {
+		x := d.x
+		clr, _ := c.barColors(d.OHLCV)
+
+		batch.addLine(x, c.priceToY(d.High), x, c.priceToY(d.Low), float32(c.layout.BarWidth), clr)
+		if withOpen {
+			openY := c.priceToY(d.Open)
+			batch.addLine(x-tick, openY, x, openY, c.layout.Px32(1), clr)
+		}
+		closeY := c.priceToY(d.Close)
+		batch.addLine(x, closeY, x+tick, closeY, c.layout.Px32(1), clr)
+	}
+}
+
+// closePath builds a path through the closes of the visible bars
+func (c *Chart) closePath(start, end int) *Path {
+	var path Path
+	for k, p := range c.closePoints(start, end) {
+		if k == 0 {
+			path.MoveTo(p[0], p[1])
+		} else {
+			path.LineTo(p[0], p[1])
+		}
+	}
+	return &path
+}
+
+func (c *Chart) drawLine(screen Renderer, start, end int) {
+	screen.StrokePath(c.closePath(start, end), c.layout.LineWidth, c.config.LineColor)
+}
+
+func (c *Chart) drawArea(screen Renderer, start, end int) {
+	bottom := float32(c.panes.Main().Bottom)
+
+	bars := c.visibleBars(start, end)
+	if len(bars) == 0 {
+		return
+	}
+	fill := c.closePath(start, end)
+	fill.LineTo(bars[len(bars)-1].x, bottom)
+	fill.LineTo(bars[0].x, bottom)
+	fill.Close()
+	screen.FillPath(fill, c.config.AreaFillColor)
+
+	screen.StrokePath(c.closePath(start, end), c.layout.LineWidth, c.config.LineColor)
+}
+
+// drawBaseline draws the close line and its fill in one colour above the
+// reference price and another below it, splitting segments that cross it
+func (c *Chart) drawBaseline(screen Renderer, start, end int) {
+	base := c.config.BaselinePrice
+	if base == 0 {
+		base = c.Data[start].Close
+	}
+	baseY := c.priceToY(base)
+
+	var upFill, downFill, upLine, downLine Path
+	addSegment := func(x0, y0, x1, y1 float32) {
+		fill, line := &upFill, &upLine
+		if y0 > baseY || y1 > baseY {
+			fill, line = &downFill, &downLine
+		}
+		fill.MoveTo(x0, baseY)
+		fill.LineTo(x0, y0)
+		fill.LineTo(x1, y1)
+		fill.LineTo(x1, baseY)
+		fill.Close()
+		line.MoveTo(x0, y0)
+		line.LineTo(x1, y1)
+	}
+
+	points := c.closePoints(start, end)
+	for k := 1; k < len(points); k++ {
+		x0, y0 := points[k-1][0], points[k-1][1]
+		x1, y1 := points[k][0], points[k][1]
+		if (y0 < baseY) != (y1 < baseY) && y0 != y1 {
+			// Split the segment where it crosses the baseline
+			xi := x0 + (x1-x0)*(baseY-y0)/(y1-y0)
+			addSegment(x0, y0, xi, baseY)
+			addSegment(xi, baseY, x1, y1)
+			continue
+		}
+		addSegment(x0, y0, x1, y1)
+	}
+
+	screen.FillPath(&upFill, c.config.BaselineUpFill)
+	screen.FillPath(&downFill, c.config.BaselineDownFill)
+	screen.StrokePath(&upLine, c.layout.LineWidth, c.config.BaselineUpColor)
+	screen.StrokePath(&downLine, c.layout.LineWidth, c.config.BaselineDownColor)
+
+	right := float32(c.layout.Width - c.layout.RightMargin)
+	screen.StrokeLine(c.barX(0), baseY, right, baseY, c.layout.Px32(1), c.config.AxisColor)
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
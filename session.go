@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log"
+	"time"
+	_ "time/tzdata" // Session timezones resolve on systems without a zoneinfo database
+)
+
+const dayMs = 24 * 60 * 60 * 1000
+
+// Session places the trading day, which starts Start after midnight in
+// Location. Daily bars and market profiles both split time at its starts.
+type Session struct {
+	Location *time.Location
+	Start    time.Duration
+}
+
+// utcSession runs from midnight to midnight UTC
+var utcSession = Session{Location: time.UTC}
+
+// session returns the configured trading session, falling back to UTC for
+// an unknown timezone
+func (c ChartConfig) session() Session {
+	loc, err := time.LoadLocation(c.SessionTimezone)
+	if err != nil {
+		log.Printf("Unknown session timezone %q, using UTC: %v", c.SessionTimezone, err)
+		loc = time.UTC
+	}
+	return Session{Location: loc, Start: c.SessionStart}
+}
+
+// dayStart returns the start of the session containing t, in milliseconds
+func (s Session) dayStart(t int64) int64 {
+	local := msToTime(t).In(s.Location).Add(-s.Start)
+	return s.startOn(local.Year(), local.Month(), local.Day())
+}
+
+// nextDay returns the start of the session after the one starting at start
+func (s Session) nextDay(start int64) int64 {
+	local := msToTime(start).In(s.Location).Add(-s.Start)
+	return s.startOn(local.Year(), local.Month(), local.Day()+1)
+}
+
+// startOn returns the start of the session of a calendar day, by the wall
+// clock so it holds across daylight saving changes. time.Date normalises
+// the date, so day may run past the month.
+func (s Session) startOn(year int, month time.Month, day int) int64 {
+	return time.Date(year, month, day, 0, 0, 0, int(s.Start), s.Location).UnixMilli()
+}
+
+// barBounds returns the start and end of the bar of intervalMs containing
+// t. Bars of whole days start with a session, groups of several days
+// counted from 1 January 1970; shorter bars align to the interval.
+func (s Session) barBounds(t, intervalMs int64) (start, end int64) {
+	if intervalMs < dayMs || intervalMs%dayMs != 0 {
+		start = t - t%intervalMs
+		return start, start + intervalMs
+	}
+	days := int(intervalMs / dayMs)
+	local := msToTime(t).In(s.Location).Add(-s.Start)
+	epochDay := int(time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC).Unix() / (dayMs / 1000))
+	first := local.Day() - epochDay%days
+	return s.startOn(local.Year(), local.Month(), first), s.startOn(local.Year(), local.Month(), first+days)
+}
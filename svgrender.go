@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+
+	"golang.org/x/image/font"
+)
+
+// svgRenderer writes the drawing as SVG elements
+type svgRenderer struct {
+	doc    *svgDocument
+	bounds image.Rectangle
+	clip   string // Attribute referencing the active clip path, if any
+}
+
+// svgDocument collects the elements of all renderers sharing a picture
+type svgDocument struct {
+	width, height int
+	defs          strings.Builder
+	body          strings.Builder
+	clips         int
+}
+
+func newSVGRenderer(width, height int) *svgRenderer {
+	return &svgRenderer{
+		doc:    &svgDocument{width: width, height: height},
+		bounds: image.Rect(0, 0, width, height),
+	}
+}
+
+// WriteTo writes the finished SVG file
+func (r *svgRenderer) WriteTo(w io.Writer) (int64, error) {
+	d := r.doc
+	n, err := fmt.Fprintf(w,
+		"<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n<defs>\n%s</defs>\n%s</svg>\n",
+		d.width, d.height, d.width, d.height, d.defs.String(), d.body.String())
+	return int64(n), err
+}
+
+func (r *svgRenderer) Bounds() image.Rectangle {
+	return r.bounds
+}
+
+func (r *svgRenderer) Fill(clr color.Color) {
+	b := r.bounds
+	r.FillRect(float32(b.Min.X), float32(b.Min.Y), float32(b.Dx()), float32(b.Dy()), clr)
+}
+
+func (r *svgRenderer) FillRect(x, y, width, height float32, clr color.Color) {
+	if width < 0 {
+		x, width = x+width, -width
+	}
+	if height < 0 {
+		y, height = y+height, -height
+	}
+	fmt.Fprintf(&r.doc.body, "<rect x=\"%.2f\" y=\"%.2f\" width=\"%.2f\" height=\"%.2f\" %s%s/>\n",
+		x, y, width, height, svgPaint("fill", clr), r.clip)
+}
+
+func (r *svgRenderer) StrokeLine(x0, y0, x1, y1, width float32, clr color.Color) {
+	fmt.Fprintf(&r.doc.body, "<line x1=\"%.2f\" y1=\"%.2f\" x2=\"%.2f\" y2=\"%.2f\" stroke-width=\"%.2f\" %s%s/>\n",
+		x0, y0, x1, y1, width, svgPaint("stroke", clr), r.clip)
+}
+
+func (r *svgRenderer) StrokeRect(x, y, width, height, strokeWidth float32, clr color.Color) {
+	fmt.Fprintf(&r.doc.body, "<rect x=\"%.2f\" y=\"%.2f\" width=\"%.2f\" height=\"%.2f\" fill=\"none\" stroke-width=\"%.2f\" %s%s/>\n",
+		x, y, width, height, strokeWidth, svgPaint("stroke", clr), r.clip)
+}
+
+func (r *svgRenderer) StrokePath(path *Path, width float32, clr color.Color) {
+	fmt.Fprintf(&r.doc.body, "<path d=\"%s\" fill=\"none\" stroke-width=\"%.2f\" stroke-linejoin=\"round\" %s%s/>\n",
+		svgPathData(path), width, svgPaint("stroke", clr), r.clip)
+}
+
+func (r *svgRenderer) FillPath(path *Path, clr color.Color) {
+	fmt.Fprintf(&r.doc.body, "<path d=\"%s\" fill-rule=\"nonzero\" %s%s/>\n",
+		svgPathData(path), svgPaint("fill", clr), r.clip)
+}
+
+func (r *svgRenderer) DrawBatch(batch *quadBatch) {
+	for _, rect := range batch.rects {
+		r.FillRect(rect.x, rect.y, rect.width, rect.height, rect.clr)
+	}
+}
+
+// Text sizes the font so its ascent matches the face's
+func (r *svgRenderer) Text(s string, face font.Face, x, y int, clr color.Color) {
+	size := float64(face.Metrics().Ascent) / 64 / goFontAscent
+	fmt.Fprintf(&r.doc.body, "<text x=\"%d\" y=\"%d\" font-family=\"Go, sans-serif\" font-size=\"%.1f\" %s%s>%s</text>\n",
+		x, y, size, svgPaint("fill", clr), r.clip, html.EscapeString(s))
+}
+
+func (r *svgRenderer) Clip(rect image.Rectangle) Renderer {
+	rect = rect.Intersect(r.bounds)
+	d := r.doc
+	d.clips++
+	id := fmt.Sprintf("clip%d", d.clips)
+	fmt.Fprintf(&d.defs, "<clipPath id=\"%s\"><rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\"/></clipPath>\n",
+		id, rect.Min.X, rect.Min.Y, rect.Dx(), rect.Dy())
+	return &svgRenderer{doc: d, bounds: rect, clip: fmt.Sprintf(" clip-path=\"url(#%s)\"", id)}
+}
+
+// goFontAscent is the ascent of the Go Regular font as a fraction of its size
+const goFontAscent = 0.9
+
+// svgPaint returns the fill or stroke attributes for a premultiplied colour
+func svgPaint(attr string, clr color.Color) string {
+	c := color.NRGBAModel.Convert(clr).(color.NRGBA)
+	s := fmt.Sprintf("%s=\"#%02x%02x%02x\"", attr, c.R, c.G, c.B)
+	if c.A != 255 {
+		s += fmt.Sprintf(" %s-opacity=\"%.3f\"", attr, float64(c.A)/255)
+	}
+	return s
+}
+
+func svgPathData(path *Path) string {
+	var b strings.Builder
+	for _, sub := range path.subpaths {
+		for i, p := range sub {
+			cmd := "L"
+			if i == 0 {
+				cmd = "M"
+			}
+			fmt.Fprintf(&b, "%s%.2f %.2f ", cmd, p.x, p.y)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
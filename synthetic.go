@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// SyntheticDef names an instrument computed from other stored symbols, such
+// as "ETHUSDT/BTCUSDT" or "(BTCUSDT+ETHUSDT)/2"
+type SyntheticDef struct {
+	Name string
+	Expr string
+}
+
+// findSynthetic returns the entry of DefaultSynthetics called name
+func findSynthetic(name string) (SyntheticDef, bool) {
+	for _, def := range DefaultSynthetics {
+		if def.Name == name {
+			return def, true
+		}
+	}
+	return SyntheticDef{}, false
+}
+
+// Synthetic evaluates an expression over the minute bars of its symbols, so
+// it can feed a Timeframe like any stored symbol. Open and close are exact.
+// High and low combine the operands' ranges with interval arithmetic: they
+// bound every price the expression can have reached within the minute, since
+// the operands' highs and lows need not have happened at the same moment.
+// Volume is taken from the first symbol in the expression.
+type Synthetic struct {
+	Name   string
+	expr   *exprNode
+	stores map[string]*Database
+}
+
+// NewSynthetic parses def and opens the stores its symbols need with open
+func NewSynthetic(def SyntheticDef, open func(symbol string) (*Database, error)) (*Synthetic, error) {
+	expr, err := parseExpr(def.Expr)
+	if err != nil {
+		return nil, fmt.Errorf("synthetic %s: %v", def.Name, err)
+	}
+	s := &Synthetic{Name: def.Name, expr: expr, stores: make(map[string]*Database)}
+	for _, symbol := range expr.symbols(nil) {
+		db, err := open(symbol)
+		if err != nil {
+			return nil, fmt.Errorf("synthetic %s: %v", def.Name, err)
+		}
+		s.stores[symbol] = db
+	}
+	return s, nil
+}
+
+// Stores returns the databases of the symbols in the expression, first
+// symbol first
+func (s *Synthetic) Stores() []*Database {
+	var stores []*Database
+	for _, symbol := range s.expr.symbols(nil) {
+		stores = append(stores, s.stores[symbol])
+	}
+	return stores
+}
+
+// Minute evaluates the expression over the bars opening at t. A minute is
+// missing if any symbol lacks it or a divisor's range includes zero.
+func (s *Synthetic) Minute(t int64) (OHLCV, bool, error) {
+	bars := make(map[string]OHLCV, len(s.stores))
+	for symbol, db := range s.stores {
+		bar, ok, err := db.Minute(t)
+		if err != nil || !ok {
+			return OHLCV{}, false, err
+		}
+		bars[symbol] = bar
+	}
+	v, ok := s.expr.eval(bars)
+	if !ok {
+		return OHLCV{}, false, nil
+	}
+	first := s.expr.symbols(nil)
+	var volume float64
+	if len(first) > 0 {
+		volume = bars[first[0]].Volume
+	}
+	return OHLCV{
+		Time:   t,
+		Open:   v.open,
+		High:   math.Max(v.high, math.Max(v.open, v.close)),
+		Low:    math.Min(v.low, math.Min(v.open, v.close)),
+		Close:  v.close,
+		Volume: volume,
+	}, true, nil
+}
+
+// barValue is an expression's value over one minute: the exact open and
+// close, and the range [low, high] the value stayed within
+type barValue struct {
+	open, close float64
+	low, high   float64
+}
+
+func constValue(k float64) barValue {
+	return barValue{open: k, close: k, low: k, high: k}
+}
+
+func (a barValue) add(b barValue) barValue {
+	return barValue{a.open + b.open, a.close + b.close, a.low + b.low, a.high + b.high}
+}
+
+func (a barValue) sub(b barValue) barValue {
+	return barValue{a.open - b.open, a.close - b.close, a.low - b.high, a.high - b.low}
+}
+
+func (a barValue) mul(b barValue) barValue {
+	p := [4]float64{a.low * b.low, a.low * b.high, a.high * b.low, a.high * b.high}
+	lo, hi := p[0], p[0]
+	for _, v := range p[1:] {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	return barValue{a.open * b.open, a.close * b.close, lo, hi}
+}
+
+// div is undefined when b's range includes zero
+func (a barValue) div(b barValue) (barValue, bool) {
+	if b.low <= 0 && b.high >= 0 {
+		return barValue{}, false
+	}
+	v := a.mul(barValue{low: 1 / b.high, high: 1 / b.low})
+	v.open, v.close = a.open/b.open, a.close/b.close
+	return v, true
+}
+
+func (a barValue) neg() barValue {
+	return barValue{-a.open, -a.close, -a.high, -a.low}
+}
+
+// exprNode is a parsed expression: a symbol, a constant, or an operator
+// applied to its operands
+type exprNode struct {
+	op          byte // '+', '-', '*', '/', 'n' for negation, 0 for a leaf
+	symbol      string
+	value       float64
+	left, right *exprNode
+}
+
+func (e *exprNode) eval(bars map[string]OHLCV) (barValue, bool) {
+	switch e.op {
+	case 0:
+		if e.symbol == "" {
+			return constValue(e.value), true
+		}
+		b := bars[e.symbol]
+		return barValue{b.Open, b.Close, b.Low, b.High}, true
+	case 'n':
+		v, ok := e.left.eval(bars)
+		return v.neg(), ok
+	}
+
+	a, ok := e.left.eval(bars)
+	if !ok {
+		return barValue{}, false
+	}
+	b, ok := e.right.eval(bars)
+	if !ok {
+		return barValue{}, false
+	}
+	switch e.op {
+	case '+':
+		return a.add(b), true
+	case '-':
+		return a.sub(b), true
+	case '*':
+		return a.mul(b), true
+	default:
+		return a.div(b)
+	}
+}
+
+// symbols appends the expression's distinct symbols in order of appearance
+func (e *exprNode) symbols(dst []string) []string {
+	if e == nil {
+		return dst
+	}
+	if e.symbol != "" {
+		for _, s := range dst {
+			if s == e.symbol {
+				return dst
+			}
+		}
+		return append(dst, e.symbol)
+	}
+	return e.right.symbols(e.left.symbols(dst))
+}
+
+// isExpr reports whether symbol is an expression rather than a plain symbol
+func isExpr(symbol string) bool {
+	return strings.ContainsAny(symbol, "+-*/() ")
+}
+
+// parseExpr parses +, -, *, / with the usual precedence, unary minus,
+// parentheses, numbers and symbol names
+func parseExpr(s string) (*exprNode, error) {
+	p := &exprParser{tokens: tokenize(s)}
+	e, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return e, nil
+}
+
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) sum() (*exprNode, error) {
+	left, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == "+" || op == "-"; op = p.peek() {
+		p.pos++
+		right, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprNode{op: op[0], left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) product() (*exprNode, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == "*" || op == "/"; op = p.peek() {
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = &exprNode{op: op[0], left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) unary() (*exprNode, error) {
+	if p.peek() == "-" {
+		p.pos++
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNode{op: 'n', left: operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (*exprNode, error) {
+	tok := p.peek()
+	switch tok {
+	case "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case "(":
+		p.pos++
+		e, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return e, nil
+	case ")", "+", "-", "*", "/":
+		return nil, fmt.Errorf("unexpected %q", tok)
+	}
+	p.pos++
+	// Symbols may start with a digit, so only whole numbers are constants
+	if v, err := strconv.ParseFloat(tok, 64); err == nil {
+		return &exprNode{value: v}, nil
+	}
+	return &exprNode{symbol: tok}, nil
+}
+
+// tokenize splits an expression into operators, parentheses and names
+func tokenize(s string) []string {
+	var tokens []string
+	start := -1
+	for i, r := range s {
+		if strings.ContainsRune("+-*/() \t", r) {
+			if start != -1 {
+				tokens = append(tokens, s[start:i])
+				start = -1
+			}
+			if r != ' ' && r != '\t' {
+				tokens = append(tokens, string(r))
+			}
+			continue
+		}
+		if start == -1 {
+			start = i
+		}
+	}
+	if start != -1 {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Color is a colour written as "#rrggbb" or "#rrggbbaa" in theme files
+type Color color.RGBA
+
+func (c Color) MarshalJSON() ([]byte, error) {
+	s := fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	if c.A != 255 {
+		s += fmt.Sprintf("%02x", c.A)
+	}
+	return json.Marshal(s)
+}
+
+func (c *Color) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	var r, g, b, a uint8
+	if len(hex) != 8 {
+		return fmt.Errorf("invalid colour %q", s)
+	}
+	if _, err := fmt.Sscanf(hex, "%02x%02x%02x%02x", &r, &g, &b, &a); err != nil {
+		return fmt.Errorf("invalid colour %q", s)
+	}
+	*c = Color{R: r, G: g, B: b, A: a}
+	return nil
+}
+
+// premultiplied returns the colour with alpha premultiplied, as the filled
+// series styles expect
+func (c Color) premultiplied() color.RGBA {
+	mul := func(v uint8) uint8 { return uint8((uint16(v)*uint16(c.A) + 127) / 255) }
+	return color.RGBA{R: mul(c.R), G: mul(c.G), B: mul(c.B), A: c.A}
+}
+
+// Theme is the set of chart colours. Fill colours are written straight and
+// premultiplied when the theme is applied.
+type Theme struct {
+	Name string `json:"name"`
+	// Base names the theme a file starts from; colours it leaves out are
+	// taken from there
+	Base string `json:"base,omitempty"`
+
+	Background    Color `json:"background"`
+	Axis          Color `json:"axis"`
+	Grid          Color `json:"grid"`
+	Label         Color `json:"label"`
+	PrimaryGrid   Color `json:"primary_grid"`
+	SecondaryGrid Color `json:"secondary_grid"`
+
+	Up               Color `json:"up"`
+	Down             Color `json:"down"`
+	UpWick           Color `json:"up_wick"`
+	DownWick         Color `json:"down_wick"`
+	Line             Color `json:"line"`
+	AreaFill         Color `json:"area_fill"`
+	BaselineUp       Color `json:"baseline_up"`
+	BaselineDown     Color `json:"baseline_down"`
+	BaselineUpFill   Color `json:"baseline_up_fill"`
+	BaselineDownFill Color `json:"baseline_down_fill"`
+
+	VolumeUp   Color   `json:"volume_up"`
+	VolumeDown Color   `json:"volume_down"`
+	VolumeMA   Color   `json:"volume_ma"`
+	Compare    []Color `json:"compare"`
+
+	Profile           Color `json:"profile"`
+	ProfileValueArea  Color `json:"profile_value_area"`
+	ProfilePOC        Color `json:"profile_poc"`
+	TPOInitialBalance Color `json:"tpo_initial_balance"`
+	TPOSingle         Color `json:"tpo_single"`
+
+	Crosshair     Color `json:"crosshair"`
+	CrosshairText Color `json:"crosshair_text"`
+	CrosshairBg   Color `json:"crosshair_bg"`
+	Selection     Color `json:"selection"`
+	FrameTimeText Color `json:"frame_time_text"`
+	FrameTimeBg   Color `json:"frame_time_bg"`
+}
+
+// Apply copies the theme's colours into config
+func (t *Theme) Apply(config *ChartConfig) {
+	config.Theme = t.Name
+	config.BackgroundColor = color.RGBA(t.Background)
+	config.AxisColor = color.RGBA(t.Axis)
+	config.GridColor = color.RGBA(t.Grid)
+	config.LabelColor = color.RGBA(t.Label)
+	config.PrimaryGridColor = color.RGBA(t.PrimaryGrid)
+	config.SecondaryGridColor = color.RGBA(t.SecondaryGrid)
+
+	config.UpColor = color.RGBA(t.Up)
+	config.DownColor = color.RGBA(t.Down)
+	config.UpWickColor = color.RGBA(t.UpWick)
+	config.DownWickColor = color.RGBA(t.DownWick)
+	config.LineColor = color.RGBA(t.Line)
+	config.AreaFillColor = t.AreaFill.premultiplied()
+	config.BaselineUpColor = color.RGBA(t.BaselineUp)
+	config.BaselineDownColor = color.RGBA(t.BaselineDown)
+	config.BaselineUpFill = t.BaselineUpFill.premultiplied()
+	config.BaselineDownFill = t.BaselineDownFill.premultiplied()
+
+	config.VolumeUpColor = color.RGBA(t.VolumeUp)
+	config.VolumeDownColor = color.RGBA(t.VolumeDown)
+	config.VolumeMAColor = color.RGBA(t.VolumeMA)
+	config.CompareColors = make([]color.RGBA, len(t.Compare))
+	for i, c := range t.Compare {
+		config.CompareColors[i] = color.RGBA(c)
+	}
+
+	config.ProfileColor = t.Profile.premultiplied()
+	config.ProfileValueAreaColor = t.ProfileValueArea.premultiplied()
+	config.ProfilePOCColor = color.RGBA(t.ProfilePOC)
+	config.TPOInitialBalanceColor = color.RGBA(t.TPOInitialBalance)
+	config.TPOSingleColor = color.RGBA(t.TPOSingle)
+
+	config.CrosshairColor = color.RGBA(t.Crosshair)
+	config.CrosshairTextColor = color.RGBA(t.CrosshairText)
+	config.CrosshairBgColor = color.RGBA(t.CrosshairBg)
+	config.SelectionColor = t.Selection.premultiplied()
+	config.FrameTimeMATextColor = color.RGBA(t.FrameTimeText)
+	config.FrameTimeMABgColor = color.RGBA(t.FrameTimeBg)
+}
+
+// DarkTheme is the default near-black theme
+var DarkTheme = Theme{
+	Name:          "dark",
+	Background:    Color{R: 10, G: 10, B: 10, A: 255},    // Near black
+	Axis:          Color{R: 100, G: 100, B: 100, A: 255}, // Mid grey
+	Grid:          Color{R: 50, G: 50, B: 50, A: 255},    // Dark grey
+	Label:         Color{R: 255, G: 255, B: 255, A: 255}, // White
+	PrimaryGrid:   Color{R: 15, G: 15, B: 15, A: 255},    // Darker time segments
+	SecondaryGrid: Color{R: 35, G: 35, B: 35, A: 255},    // Lighter time segments
+
+	Up:               Color{R: 38, G: 166, B: 154, A: 255}, // Teal
+	Down:             Color{R: 239, G: 83, B: 80, A: 255},  // Red
+	UpWick:           Color{R: 38, G: 166, B: 154, A: 255},
+	DownWick:         Color{R: 239, G: 83, B: 80, A: 255},
+	Line:             Color{R: 41, G: 98, B: 255, A: 255}, // Blue
+	AreaFill:         Color{R: 41, G: 98, B: 255, A: 64},
+	BaselineUp:       Color{R: 38, G: 166, B: 154, A: 255},
+	BaselineDown:     Color{R: 239, G: 83, B: 80, A: 255},
+	BaselineUpFill:   Color{R: 38, G: 166, B: 154, A: 64},
+	BaselineDownFill: Color{R: 239, G: 83, B: 80, A: 64},
+
+	VolumeUp:   Color{R: 0, G: 100, B: 0, A: 255},   // Dark green
+	VolumeDown: Color{R: 100, G: 0, B: 0, A: 255},   // Dark red
+	VolumeMA:   Color{R: 255, G: 193, B: 7, A: 255}, // Amber
+	Compare: []Color{
+		{R: 255, G: 152, B: 0, A: 255},  // Orange
+		{R: 171, G: 71, B: 188, A: 255}, // Purple
+		{R: 255, G: 235, B: 59, A: 255}, // Yellow
+		{R: 0, G: 188, B: 212, A: 255},  // Cyan
+	},
+
+	Profile:           Color{R: 120, G: 120, B: 120, A: 72},
+	ProfileValueArea:  Color{R: 41, G: 98, B: 255, A: 96},
+	ProfilePOC:        Color{R: 255, G: 152, B: 0, A: 255},  // Orange
+	TPOInitialBalance: Color{R: 0, G: 188, B: 212, A: 255},  // Cyan
+	TPOSingle:         Color{R: 255, G: 235, B: 59, A: 255}, // Yellow
+
+	Crosshair:     Color{R: 150, G: 150, B: 150, A: 255},
+	CrosshairText: Color{R: 200, G: 200, B: 200, A: 255},
+	CrosshairBg:   Color{R: 30, G: 30, B: 30, A: 255},
+	Selection:     Color{R: 100, G: 150, B: 255, A: 40},
+	FrameTimeText: Color{R: 100, G: 100, B: 100, A: 255},
+	FrameTimeBg:   Color{R: 20, G: 20, B: 20, A: 255},
+}
+
+// LightTheme is dark text and bars on white
+var LightTheme = Theme{
+	Name:          "light",
+	Background:    Color{R: 255, G: 255, B: 255, A: 255}, // White
+	Axis:          Color{R: 150, G: 150, B: 150, A: 255}, // Mid grey
+	Grid:          Color{R: 225, G: 225, B: 225, A: 255}, // Light grey
+	Label:         Color{R: 19, G: 23, B: 34, A: 255},    // Near black
+	PrimaryGrid:   Color{R: 255, G: 255, B: 255, A: 255},
+	SecondaryGrid: Color{R: 245, G: 246, B: 248, A: 255},
+
+	Up:               Color{R: 8, G: 153, B: 129, A: 255}, // Green
+	Down:             Color{R: 242, G: 54, B: 69, A: 255}, // Red
+	UpWick:           Color{R: 8, G: 153, B: 129, A: 255},
+	DownWick:         Color{R: 242, G: 54, B: 69, A: 255},
+	Line:             Color{R: 41, G: 98, B: 255, A: 255},
+	AreaFill:         Color{R: 41, G: 98, B: 255, A: 48},
+	BaselineUp:       Color{R: 8, G: 153, B: 129, A: 255},
+	BaselineDown:     Color{R: 242, G: 54, B: 69, A: 255},
+	BaselineUpFill:   Color{R: 8, G: 153, B: 129, A: 48},
+	BaselineDownFill: Color{R: 242, G: 54, B: 69, A: 48},
+
+	VolumeUp:   Color{R: 150, G: 210, B: 200, A: 255}, // Pale green
+	VolumeDown: Color{R: 250, G: 175, B: 180, A: 255}, // Pale red
+	VolumeMA:   Color{R: 41, G: 98, B: 255, A: 255},   // Blue
+	Compare: []Color{
+		{R: 230, G: 120, B: 0, A: 255},  // Orange
+		{R: 142, G: 36, B: 170, A: 255}, // Purple
+		{R: 180, G: 150, B: 0, A: 255},  // Ochre
+		{R: 0, G: 131, B: 143, A: 255},  // Dark cyan
+	},
+
+	Profile:           Color{R: 120, G: 123, B: 134, A: 64},
+	ProfileValueArea:  Color{R: 41, G: 98, B: 255, A: 72},
+	ProfilePOC:        Color{R: 230, G: 120, B: 0, A: 255}, // Orange
+	TPOInitialBalance: Color{R: 0, G: 131, B: 143, A: 255}, // Dark cyan
+	TPOSingle:         Color{R: 180, G: 150, B: 0, A: 255}, // Ochre
+
+	Crosshair:     Color{R: 120, G: 123, B: 134, A: 255},
+	CrosshairText: Color{R: 255, G: 255, B: 255, A: 255},
+	CrosshairBg:   Color{R: 19, G: 23, B: 34, A: 255},
+	Selection:     Color{R: 41, G: 98, B: 255, A: 32},
+	FrameTimeText: Color{R: 120, G: 120, B: 120, A: 255},
+	FrameTimeBg:   Color{R: 240, G: 240, B: 240, A: 255},
+}
+
+// HighContrastTheme uses pure black and white with saturated bars
+var HighContrastTheme = Theme{
+	Name:          "high-contrast",
+	Background:    Color{R: 0, G: 0, B: 0, A: 255},       // Black
+	Axis:          Color{R: 255, G: 255, B: 255, A: 255}, // White
+	Grid:          Color{R: 128, G: 128, B: 128, A: 255}, // Mid grey
+	Label:         Color{R: 255, G: 255, B: 255, A: 255},
+	PrimaryGrid:   Color{R: 0, G: 0, B: 0, A: 255},
+	SecondaryGrid: Color{R: 28, G: 28, B: 28, A: 255},
+
+	Up:               Color{R: 0, G: 255, B: 0, A: 255}, // Green
+	Down:             Color{R: 255, G: 0, B: 0, A: 255}, // Red
+	UpWick:           Color{R: 0, G: 255, B: 0, A: 255},
+	DownWick:         Color{R: 255, G: 0, B: 0, A: 255},
+	Line:             Color{R: 255, G: 255, B: 0, A: 255}, // Yellow
+	AreaFill:         Color{R: 255, G: 255, B: 0, A: 64},
+	BaselineUp:       Color{R: 0, G: 255, B: 0, A: 255},
+	BaselineDown:     Color{R: 255, G: 0, B: 0, A: 255},
+	BaselineUpFill:   Color{R: 0, G: 255, B: 0, A: 64},
+	BaselineDownFill: Color{R: 255, G: 0, B: 0, A: 64},
+
+	VolumeUp:   Color{R: 0, G: 160, B: 0, A: 255},
+	VolumeDown: Color{R: 160, G: 0, B: 0, A: 255},
+	VolumeMA:   Color{R: 255, G: 255, B: 0, A: 255},
+	Compare: []Color{
+		{R: 0, G: 255, B: 255, A: 255}, // Cyan
+		{R: 255, G: 0, B: 255, A: 255}, // Magenta
+		{R: 255, G: 128, B: 0, A: 255}, // Orange
+		{R: 255, G: 255, B: 255, A: 255},
+	},
+
+	Profile:           Color{R: 255, G: 255, B: 255, A: 64},
+	ProfileValueArea:  Color{R: 0, G: 255, B: 255, A: 96},
+	ProfilePOC:        Color{R: 255, G: 255, B: 0, A: 255},
+	TPOInitialBalance: Color{R: 0, G: 255, B: 255, A: 255},
+	TPOSingle:         Color{R: 255, G: 0, B: 255, A: 255},
+
+	Crosshair:     Color{R: 255, G: 255, B: 255, A: 255},
+	CrosshairText: Color{R: 0, G: 0, B: 0, A: 255},
+	CrosshairBg:   Color{R: 255, G: 255, B: 255, A: 255},
+	Selection:     Color{R: 255, G: 255, B: 255, A: 48},
+	FrameTimeText: Color{R: 255, G: 255, B: 255, A: 255},
+	FrameTimeBg:   Color{R: 0, G: 0, B: 0, A: 255},
+}
+
+// ColorBlindTheme is the dark theme with blue and orange for up and down,
+// which stay distinct under the common forms of colour blindness
+var ColorBlindTheme = func() Theme {
+	t := DarkTheme
+	t.Name = "color-blind"
+	blue, orange := Color{R: 0, G: 114, B: 178, A: 255}, Color{R: 230, G: 159, B: 0, A: 255}
+	t.Up, t.UpWick, t.BaselineUp = blue, blue, blue
+	t.Down, t.DownWick, t.BaselineDown = orange, orange, orange
+	t.BaselineUpFill = Color{R: 0, G: 114, B: 178, A: 64}
+	t.BaselineDownFill = Color{R: 230, G: 159, B: 0, A: 64}
+	t.VolumeUp = Color{R: 0, G: 60, B: 95, A: 255}    // Dark blue
+	t.VolumeDown = Color{R: 115, G: 80, B: 0, A: 255} // Dark orange
+	t.VolumeMA = Color{R: 240, G: 228, B: 66, A: 255} // Yellow
+	t.Line = Color{R: 86, G: 180, B: 233, A: 255}     // Sky blue
+	t.AreaFill = Color{R: 86, G: 180, B: 233, A: 64}
+	t.Compare = []Color{
+		{R: 204, G: 121, B: 167, A: 255}, // Reddish purple
+		{R: 240, G: 228, B: 66, A: 255},  // Yellow
+		{R: 0, G: 158, B: 115, A: 255},   // Bluish green
+		{R: 213, G: 94, B: 0, A: 255},    // Vermillion
+	}
+	t.ProfileValueArea = Color{R: 86, G: 180, B: 233, A: 96}  // Sky blue
+	t.ProfilePOC = Color{R: 204, G: 121, B: 167, A: 255}      // Reddish purple
+	t.TPOInitialBalance = Color{R: 0, G: 158, B: 115, A: 255} // Bluish green
+	t.TPOSingle = Color{R: 240, G: 228, B: 66, A: 255}        // Yellow
+	return t
+}()
+
+// Themes lists the themes that can be switched between, built-ins first
+var Themes = []Theme{DarkTheme, LightTheme, HighContrastTheme, ColorBlindTheme}
+
+// startTheme loads the themes in dir and returns the one called name,
+// falling back to the dark theme
+func startTheme(dir, name string) Theme {
+	if err := LoadThemes(dir); err != nil {
+		log.Printf("Failed to load themes: %v", err)
+	}
+	theme, ok := findTheme(name)
+	if !ok {
+		log.Printf("Unknown theme %q, using %s", name, DarkTheme.Name)
+		theme = DarkTheme
+	}
+	return theme
+}
+
+// findTheme returns the theme called name
+func findTheme(name string) (Theme, bool) {
+	for _, t := range Themes {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Theme{}, false
+}
+
+// LoadTheme reads a JSON theme file. Colours it leaves out come from its
+// base theme, or the dark theme when it names none.
+func LoadTheme(path string) (Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Theme{}, fmt.Errorf("failed to read theme: %v", err)
+	}
+	var header struct {
+		Base string `json:"base"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return Theme{}, fmt.Errorf("failed to parse theme %s: %v", path, err)
+	}
+	theme := DarkTheme
+	if header.Base != "" {
+		base, ok := findTheme(header.Base)
+		if !ok {
+			return Theme{}, fmt.Errorf("theme %s: unknown base theme %q", path, header.Base)
+		}
+		theme = base
+	}
+	// Unmarshalling into the copy would otherwise reuse the base theme's
+	// backing array and overwrite its compare colours
+	theme.Compare = slices.Clone(theme.Compare)
+	theme.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if err := json.Unmarshal(data, &theme); err != nil {
+		return Theme{}, fmt.Errorf("failed to parse theme %s: %v", path, err)
+	}
+	return theme, nil
+}
+
+// LoadThemes adds every *.json theme in dir to Themes, replacing built-ins
+// of the same name. A missing directory is not an error, and files that
+// fail to load are logged and skipped.
+func LoadThemes(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		theme, err := LoadTheme(path)
+		if err != nil {
+			log.Printf("Skipping theme: %v", err)
+			continue
+		}
+		replaced := false
+		for i := range Themes {
+			if Themes[i].Name == theme.Name {
+				Themes[i], replaced = theme, true
+			}
+		}
+		if !replaced {
+			Themes = append(Themes, theme)
+		}
+	}
+	return nil
+}
+
+// nextTheme returns the theme after the one called name
+func nextTheme(name string) Theme {
+	for i, t := range Themes {
+		if t.Name == name {
+			return Themes[(i+1)%len(Themes)]
+		}
+	}
+	return Themes[0]
+}
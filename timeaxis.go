@@ -0,0 +1,218 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// timeTick is a labelled position on the time axis
+type timeTick struct {
+	x       float64
+	label   string
+	major   bool  // Day, month or year boundary, drawn bold
+	ordinal int64 // Stable tick number, used to alternate grid segments
+}
+
+// tickInterval is a human-friendly spacing for time labels
+type tickInterval struct {
+	ms     int64
+	months int // Calendar months, for intervals that aren't a fixed length
+}
+
+var tickIntervals = []tickInterval{
+	{ms: time.Minute.Milliseconds()},
+	{ms: 5 * time.Minute.Milliseconds()},
+	{ms: 15 * time.Minute.Milliseconds()},
+	{ms: time.Hour.Milliseconds()},
+	{ms: 4 * time.Hour.Milliseconds()},
+	{ms: 24 * time.Hour.Milliseconds()},
+	{ms: 7 * 24 * time.Hour.Milliseconds()},
+	{ms: 30 * 24 * time.Hour.Milliseconds(), months: 1},
+}
+
+const defaultBarInterval = 15 * 60 * 1000
+
+// SetInterval tells the chart the length of one bar
+func (c *Chart) SetInterval(d time.Duration) {
+	c.interval = d.Milliseconds()
+}
+
+// barInterval returns the spacing between bars in milliseconds
+func (c *Chart) barInterval() int64 {
+	if c.interval > 0 {
+		return c.interval
+	}
+	if len(c.Data) < 2 || c.Data[1].Time <= c.Data[0].Time {
+		return defaultBarInterval
+	}
+	return c.Data[1].Time - c.Data[0].Time
+}
+
+// timeToX maps a timestamp onto the x axis using the bar it falls in, so gaps
+// in the data don't shift the labels away from their bars
+func (c *Chart) timeToX(t int64) float64 {
+	start, _ := c.visibleRange()
+	if start == -1 {
+		return c.layout.LeftMargin
+	}
+	interval := c.barInterval()
+	spacing := (c.layout.BarWidth + c.layout.BarSpacing) * c.Zoom
+
+	i := sort.Search(len(c.Data), func(i int) bool { return c.Data[i].Time > t }) - 1
+	if i < 0 {
+		i = 0
+	}
+	offset := float64(i-start) + float64(t-c.Data[i].Time)/float64(interval)
+	return float64(c.barX(0)) + offset*spacing
+}
+
+// barAt returns the index of the bar whose interval contains t, or -1
+func (c *Chart) barAt(t int64) int {
+	i := sort.Search(len(c.Data), func(i int) bool { return c.Data[i].Time > t }) - 1
+	if i < 0 || t >= c.Data[i].Time+c.barInterval() {
+		return -1
+	}
+	return i
+}
+
+// barIndexAt returns the index of the bar drawn nearest x, clamped to the
+// data. Bars are centred on their x, so the boundary is half a bar left.
+func (c *Chart) barIndexAt(x float64) int {
+	t := c.xToTime(x + c.barSpacing()/2)
+	i := sort.Search(len(c.Data), func(i int) bool { return c.Data[i].Time > t }) - 1
+	return max(0, min(i, len(c.Data)-1))
+}
+
+// SyncTo aligns the viewport with leader by timestamp: the left edge moves to
+// the same time as the leader's, and the zoom is set so both charts show the
+// same time span per pixel
+func (c *Chart) SyncTo(leader *Chart) {
+	if len(c.Data) == 0 {
+		return
+	}
+	zoom := leader.Zoom * float64(c.barInterval()) / float64(leader.barInterval())
+	c.Zoom = c.clampZoom(zoom)
+
+	from := leader.ts_from + int64(leader.scroll*float64(leader.barInterval()))
+	idx := sort.Search(len(c.Data), func(i int) bool { return c.Data[i].Time > from }) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	scroll := float64(from-c.Data[idx].Time) / float64(c.barInterval())
+	c.ts_from, c.scroll = c.Data[idx].Time, math.Max(0, math.Min(scroll, 0.999))
+	c.viewportChanged()
+}
+
+// xToTime maps an x coordinate back to a timestamp
+func (c *Chart) xToTime(x float64) int64 {
+	start, _ := c.visibleRange()
+	if start == -1 {
+		return 0
+	}
+	interval := c.barInterval()
+	spacing := (c.layout.BarWidth + c.layout.BarSpacing) * c.Zoom
+
+	offset := (x - float64(c.barX(0))) / spacing
+	i := start + int(math.Floor(offset))
+	frac := offset - math.Floor(offset)
+	switch {
+	case i < 0:
+		return c.Data[0].Time + int64((offset+float64(start))*float64(interval))
+	case i >= len(c.Data):
+		last := len(c.Data) - 1
+		return c.Data[last].Time + int64((offset-float64(last-start))*float64(interval))
+	}
+	return c.Data[i].Time + int64(frac*float64(interval))
+}
+
+// computeTimeTicks picks the smallest human interval whose labels keep
+// minSpacing pixels apart and lays ticks out across the visible bars
+func computeTimeTicks(c *Chart, minSpacing float64) []timeTick {
+	start, end := c.visibleRange()
+	if start == -1 || end <= start {
+		return nil
+	}
+	pxPerMs := (c.layout.BarWidth + c.layout.BarSpacing) * c.Zoom / float64(c.barInterval())
+
+	interval := tickIntervals[len(tickIntervals)-1]
+	for _, ti := range tickIntervals {
+		if ti.ms >= c.barInterval() && float64(ti.ms)*pxPerMs >= minSpacing {
+			interval = ti
+			break
+		}
+	}
+
+	from := msToTime(c.Data[start].Time)
+	to := msToTime(c.Data[end-1].Time + c.barInterval())
+	right := c.layout.Width - c.layout.RightMargin
+
+	var ticks []timeTick
+	for _, tm := range tickTimes(from, to, interval) {
+		x := c.timeToX(tm.UnixMilli())
+		if x < c.layout.LeftMargin || x > right {
+			continue
+		}
+		label, major := tickLabel(tm, interval)
+		ticks = append(ticks, timeTick{x: x, label: label, major: major, ordinal: tickOrdinal(tm, interval)})
+	}
+	return ticks
+}
+
+// tickTimes returns the interval boundaries between from and to in local time
+func tickTimes(from, to time.Time, interval tickInterval) []time.Time {
+	var times []time.Time
+	switch {
+	case interval.months > 0:
+		for t := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location()); !t.After(to); t = t.AddDate(0, interval.months, 0) {
+			times = append(times, t)
+		}
+	case interval.ms == 7*24*time.Hour.Milliseconds():
+		day := startOfDay(from)
+		day = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)) // Back to Monday
+		for t := day; !t.After(to); t = t.AddDate(0, 0, 7) {
+			times = append(times, t)
+		}
+	default:
+		// Restart at each midnight so intraday ticks align to the local day
+		step := time.Duration(interval.ms) * time.Millisecond
+		for day := startOfDay(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+			next := day.AddDate(0, 0, 1)
+			for t := day; t.Before(next) && !t.After(to); t = t.Add(step) {
+				times = append(times, t)
+			}
+		}
+	}
+	return times
+}
+
+// tickLabel formats a tick, with day, month and year boundaries marked major
+func tickLabel(t time.Time, interval tickInterval) (string, bool) {
+	midnight := t.Hour() == 0 && t.Minute() == 0
+	switch {
+	case midnight && t.Day() == 1 && t.Month() == time.January:
+		return t.Format(TimeFormat.YearlyFormat), true
+	case midnight && t.Day() == 1:
+		return t.Format(TimeFormat.MonthlyFormat), true
+	case midnight:
+		return t.Format(TimeFormat.DailyFormat), interval.ms < 24*time.Hour.Milliseconds()
+	default:
+		return t.Format(TimeFormat.IntradayFormat), false
+	}
+}
+
+func tickOrdinal(t time.Time, interval tickInterval) int64 {
+	if interval.months > 0 {
+		return int64(t.Year()*12 + int(t.Month()))
+	}
+	_, offset := t.Zone()
+	return (t.UnixMilli() + int64(offset)*1000) / interval.ms
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func msToTime(ms int64) time.Time {
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
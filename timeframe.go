@@ -3,44 +3,58 @@ package main
 import (
 	"fmt"
 	"time"
-
-	"github.com/akrylysov/pogreb"
 )
 
+// MinuteSource supplies 1-minute bars by their open time in milliseconds.
+// ok is false when the minute has no data.
+type MinuteSource interface {
+	Minute(t int64) (bar OHLCV, ok bool, err error)
+}
+
 type Timeframe struct {
-	db *pogreb.DB
+	source   MinuteSource
+	Interval time.Duration // Length of one aggregated bar
+	Bars     int           // Number of bars loaded
+	Session  Session       // Where daily bars start
+	// From and To fix the loaded range in milliseconds; while To is zero
+	// the last Bars bars are loaded
+	From, To int64
 }
 
-func NewTimeframe(db *pogreb.DB) *Timeframe {
-	return &Timeframe{db: db}
+func NewTimeframe(source MinuteSource, interval time.Duration) *Timeframe {
+	return &Timeframe{source: source, Interval: interval, Bars: 300, Session: utcSession}
+}
+
+// GetBars aggregates the stored 1-minute data into the last tf.Bars bars,
+// or the bars between From and To when a range is set
+func (tf *Timeframe) GetBars() ([]OHLCV, error) {
+	if tf.To != 0 {
+		return tf.GetRange(tf.From, tf.To)
+	}
+	endTimeMs := time.Now().UTC().Unix() * 1000
+	return tf.GetRange(endTimeMs-int64(tf.Bars)*tf.Interval.Milliseconds(), endTimeMs)
 }
 
-func (tf *Timeframe) Get15MinBars() ([]OHLCV, error) {
-	now := time.Now().UTC()
-	endTimeMs := now.Unix() * 1000
-	startTimeMs := endTimeMs - (300 * 15 * 60 * 1000) // 300 bars * 15 minutes
+// GetRange aggregates the stored 1-minute data between from and to, in
+// milliseconds, into bars. from is aligned down to a bar boundary.
+func (tf *Timeframe) GetRange(startTimeMs, endTimeMs int64) ([]OHLCV, error) {
+	intervalMs := tf.Interval.Milliseconds()
 
-	// Align start time to the nearest 15-minute boundary
-	startTimeMs = (startTimeMs / (15 * 60 * 1000)) * (15 * 60 * 1000)
+	// Align start time to the bar boundary
+	startTimeMs, _ = tf.Session.barBounds(startTimeMs, intervalMs)
 
-	// Fetch 1-minute data from the database (4500 minutes = 300 * 15)
+	// Fetch 1-minute data from the database
 	var minuteData []OHLCV
 	for t := startTimeMs; t < endTimeMs; t += 60 * 1000 {
-		key := int64ToBytes(t)
-		value, err := tf.db.Get(key)
+		ohlcv, ok, err := tf.source.Minute(t)
 		if err != nil {
-			fmt.Printf("Warning: Failed to read from db at %d: %v\n", t, err)
+			fmt.Printf("Warning: Failed to read minute at %d: %v\n", t, err)
 			continue
 		}
-		if value == nil {
+		if !ok {
 			fmt.Printf("Warning: Missing data at %d (%s)\n", t, time.Unix(t/1000, 0).UTC())
 			continue
 		}
-		ohlcv, err := deserializeOHLCV(value)
-		if err != nil {
-			fmt.Printf("Warning: Failed to deserialize OHLCV at %d: %v\n", t, err)
-			continue
-		}
 		minuteData = append(minuteData, ohlcv)
 	}
 
@@ -49,9 +63,14 @@ func (tf *Timeframe) Get15MinBars() ([]OHLCV, error) {
 		return nil, fmt.Errorf("no data available in requested timeframe")
 	}
 
-	// Aggregate into 15-minute bars
+	return aggregate(minuteData, intervalMs, tf.Session), nil
+}
+
+// aggregate combines minute bars into bars aligned to intervalMs boundaries,
+// or to session starts for daily bars
+func aggregate(minuteData []OHLCV, intervalMs int64, session Session) []OHLCV {
 	var bars []OHLCV
-	currentBarEnd := minuteData[0].Time + 15*60*1000
+	var currentBarEnd int64
 	var currentBar *OHLCV
 
 	for i := 0; i < len(minuteData); i++ {
@@ -62,16 +81,17 @@ func (tf *Timeframe) Get15MinBars() ([]OHLCV, error) {
 				bars = append(bars, *currentBar)
 			}
 
-			// Start a new bar
+			// Start a new bar on the interval boundary
+			barStart, barEnd := session.barBounds(minuteData[i].Time, intervalMs)
 			currentBar = &OHLCV{
-				Time:   minuteData[i].Time,
+				Time:   barStart,
 				Open:   minuteData[i].Open,
 				High:   minuteData[i].High,
 				Low:    minuteData[i].Low,
 				Close:  minuteData[i].Close,
 				Volume: minuteData[i].Volume,
 			}
-			currentBarEnd = minuteData[i].Time + 15*60*1000
+			currentBarEnd = barEnd
 			continue
 		}
 
@@ -91,5 +111,5 @@ func (tf *Timeframe) Get15MinBars() ([]OHLCV, error) {
 		bars = append(bars, *currentBar)
 	}
 
-	return bars, nil
+	return bars
 }
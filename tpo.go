@@ -0,0 +1,242 @@
+package main
+
+import (
+	"math"
+
+	"golang.org/x/image/font"
+)
+
+// tpoPeriodMs is the time each TPO letter stands for
+const tpoPeriodMs = 30 * 60 * 1000
+
+// tpoLetters name the periods of a session in order, wrapping after 52
+const tpoLetters = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// MarketProfile draws a TPO (time price opportunity) profile of each
+// session over the price pane: a letter for every half-hour period that
+// traded at a price, stacked left to right from the session start. The
+// initial balance, point of control, value area and single prints are
+// marked. It is built from the 1-minute bars, split at the session starts
+// the daily bars use.
+type MarketProfile struct {
+	RowSize  float64 // Price per row, 0 picks a round size giving about config.TPORows rows
+	session  Session
+	minutes  *minuteCache
+	sessions []tpoSession
+	key      tpoKey
+	last     OHLCV // Last bar when the minutes were last invalidated
+	fonts    *fontCache
+	config   ChartConfig
+	layout   *Layout
+}
+
+// tpoSession is one session's profile. Row i spans lo+i*rowSize to
+// lo+(i+1)*rowSize.
+type tpoSession struct {
+	start, end int64
+	lo         float64
+	rows       [][]int // Periods that traded in each row, in time order
+	poc        int     // Row with the most periods
+	val, vah   int     // Lowest and highest rows of the value area
+	ibLo, ibHi int     // Rows the first two periods span, -1 without them
+}
+
+type tpoKey struct {
+	from, to int64
+	last     OHLCV
+	rowSize  float64
+}
+
+func NewMarketProfile(config ChartConfig, layout *Layout, minutes *minuteCache) *MarketProfile {
+	return &MarketProfile{
+		RowSize: config.TPORowSize,
+		session: config.session(),
+		minutes: minutes,
+		fonts:   newFontCache(9),
+		config:  config,
+		layout:  layout,
+	}
+}
+
+// PriceRange leaves the fit to the bars, which the profile spans anyway
+func (p *MarketProfile) PriceRange(from, to int64) (min, max float64, ok bool) {
+	return 0, 0, false
+}
+
+// rowSize returns the price per row, rounded so it stays put while the
+// price range changes a little
+func (p *MarketProfile) rowSize(chart *Chart) float64 {
+	if p.RowSize > 0 {
+		return p.RowSize
+	}
+	return calculateStep(chart.viewMax-chart.viewMin, max(p.config.TPORows, 1))
+}
+
+// update rebuilds the sessions overlapping the visible bars when they or
+// the row size change
+func (p *MarketProfile) update(chart *Chart, start, end int) []tpoSession {
+	key := tpoKey{
+		from:    p.session.dayStart(chart.Data[start].Time),
+		last:    chart.Data[len(chart.Data)-1],
+		rowSize: p.rowSize(chart),
+	}
+	key.to = p.session.nextDay(p.session.dayStart(chart.Data[end-1].Time))
+	if key == p.key {
+		return p.sessions
+	}
+	// The forming bar's minutes are still arriving
+	if key.last != p.last {
+		p.minutes.invalidate(key.last.Time)
+		p.last = key.last
+	}
+
+	minutes, ok := p.minutes.between(key.from, key.to)
+	if !ok {
+		return p.sessions // The previous sessions until the minutes are read
+	}
+	p.sessions = p.sessions[:0]
+	for from := key.from; from < key.to; {
+		to := p.session.nextDay(from)
+		n := 0
+		for n < len(minutes) && minutes[n].Time < to {
+			n++
+		}
+		if n > 0 {
+			p.sessions = append(p.sessions, buildTPOSession(minutes[:n], from, to, key.rowSize, p.config.VolumeProfileValueArea))
+		}
+		minutes, from = minutes[n:], to
+	}
+	p.key = key
+	return p.sessions
+}
+
+// buildTPOSession lays out the periods of one session's minutes by price
+func buildTPOSession(minutes []OHLCV, start, end int64, rowSize, valueArea float64) tpoSession {
+	// High and low of each period
+	type period struct {
+		high, low float64
+		traded    bool
+	}
+	periods := make([]period, (end-start+tpoPeriodMs-1)/tpoPeriodMs)
+	for _, m := range minutes {
+		i := int((m.Time - start) / tpoPeriodMs)
+		if i < 0 || i >= len(periods) {
+			continue
+		}
+		if !periods[i].traded {
+			periods[i] = period{m.High, m.Low, true}
+			continue
+		}
+		periods[i].high = math.Max(periods[i].high, m.High)
+		periods[i].low = math.Min(periods[i].low, m.Low)
+	}
+
+	low, high := calculatePriceRange(minutes)
+	s := tpoSession{start: start, end: end, lo: math.Floor(low/rowSize) * rowSize, ibLo: -1, ibHi: -1}
+	n := min(maxProfileRows, int((high-s.lo)/rowSize)+1)
+	row := func(price float64) int {
+		return max(0, min(n-1, int((price-s.lo)/rowSize)))
+	}
+	s.rows = make([][]int, n)
+	for i, pd := range periods {
+		if !pd.traded {
+			continue
+		}
+		for r := row(pd.low); r <= row(pd.high); r++ {
+			s.rows[r] = append(s.rows[r], i)
+		}
+		// The initial balance is the range of the first hour
+		if i < 2 {
+			if s.ibLo == -1 {
+				s.ibLo, s.ibHi = row(pd.low), row(pd.high)
+			}
+			s.ibLo, s.ibHi = min(s.ibLo, row(pd.low)), max(s.ibHi, row(pd.high))
+		}
+	}
+
+	// The point of control is the longest row, the one nearest the middle
+	// of the range on a tie
+	counts := make([]float64, n)
+	total := 0.0
+	for r, periods := range s.rows {
+		counts[r] = float64(len(periods))
+		total += counts[r]
+		mid := float64(n-1) / 2
+		if counts[r] > counts[s.poc] || counts[r] == counts[s.poc] && math.Abs(float64(r)-mid) < math.Abs(float64(s.poc)-mid) {
+			s.poc = r
+		}
+	}
+	s.val, s.vah = findValueArea(counts, s.poc, total*valueArea)
+	return s
+}
+
+// Draw renders each visible session from its start, with letters in the
+// blocks when they fit
+func (p *MarketProfile) Draw(screen Renderer, chart *Chart) {
+	start, end := chart.visibleRange()
+	if start == -1 || end <= start {
+		return
+	}
+	sessions := p.update(chart, start, end)
+	rowSize := p.key.rowSize
+	face := p.fonts.get(p.layout)
+	letterWidth := float32(font.MeasureString(face, "W").Ceil())
+	ascent := float32(face.Metrics().CapHeight.Ceil())
+	spacing := float32((p.layout.BarWidth + p.layout.BarSpacing) * chart.Zoom)
+	marker := p.layout.Px32(2)
+
+	for _, s := range sessions {
+		x0 := float32(chart.timeToX(s.start)) - spacing/2
+		x1 := float32(chart.timeToX(s.end)) - spacing/2
+		longest := 0
+		for _, periods := range s.rows {
+			longest = max(longest, len(periods))
+		}
+		cell := min32(letterWidth+p.layout.Px32(2), (x1-x0-2*marker)/float32(max(longest, 1)))
+		if cell <= 0 {
+			continue
+		}
+		rowHeight := float32(math.Abs(float64(chart.priceToY(s.lo) - chart.priceToY(s.lo+rowSize))))
+		letters := cell >= letterWidth && rowHeight >= float32(p.fonts.height)*0.7
+		gap := float32(0)
+		if cell > p.layout.Px32(3) && rowHeight > p.layout.Px32(3) {
+			gap = p.layout.Px32(1)
+		}
+
+		// Initial balance along the left edge
+		if s.ibLo != -1 {
+			top := chart.priceToY(s.lo + float64(s.ibHi+1)*rowSize)
+			bottom := chart.priceToY(s.lo + float64(s.ibLo)*rowSize)
+			screen.FillRect(x0, min32(top, bottom), marker, float32(math.Abs(float64(bottom-top))), p.config.TPOInitialBalanceColor)
+		}
+
+		for r, periods := range s.rows {
+			y0 := chart.priceToY(s.lo + float64(r+1)*rowSize)
+			y1 := chart.priceToY(s.lo + float64(r)*rowSize)
+			top, bottom := min32(y0, y1), max32(y0, y1)
+
+			clr := p.config.ProfileColor
+			switch {
+			case r == s.poc:
+				clr = p.config.ProfilePOCColor
+			case len(periods) == 1 && r > 0 && r < len(s.rows)-1:
+				clr = p.config.TPOSingleColor // Single print inside the range
+			case r >= s.val && r <= s.vah:
+				clr = p.config.ProfileValueAreaColor
+			}
+			for k, period := range periods {
+				x := x0 + 2*marker + float32(k)*cell
+				screen.FillRect(x, top, cell-gap, bottom-top-gap, clr)
+				if letters {
+					letter := string(tpoLetters[period%len(tpoLetters)])
+					baseline := int(top + (bottom-top-gap+ascent)/2)
+					screen.Text(letter, face, int(x+(cell-gap-letterWidth)/2), baseline, p.config.LabelColor)
+				}
+			}
+		}
+
+		// Point of control across the session
+		y := chart.priceToY(s.lo + (float64(s.poc)+0.5)*rowSize)
+		screen.StrokeLine(x0, y, x1, y, p.layout.Px32(1), p.config.ProfilePOCColor)
+	}
+}
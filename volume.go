@@ -1,85 +1,212 @@
 package main
 
 import (
+	"fmt"
 	"image/color"
+	"math"
+)
+
+// VolumePlacement selects where the volume is shown
+type VolumePlacement int
 
-	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/vector"
+const (
+	VolumeInPane  VolumePlacement = iota // Its own pane under the price pane
+	VolumeOverlay                        // Along the bottom of the price pane
+	VolumeHidden
+	volumePlacementCount
 )
 
 type Volume struct {
+	Quote    bool // Show volume in the quote currency
+	MAPeriod int  // Bars in the moving average line, 0 hides it
+	// band is where the volume is drawn as an overlay, along the bottom of
+	// the price pane with its own scale
+	band   Pane
 	config ChartConfig
+	layout *Layout
+	bars   quadBatch // Rebuilt when key changes
+	key    volumeKey
+	ma     []volumePoint // Moving average per drawn column, rebuilt when maKey changes
+	maKey  volumeMAKey
 }
 
-func NewVolume(config ChartConfig) *Volume {
-	return &Volume{config: config}
+type volumeKey struct {
+	geometryKey
+	quote bool
 }
 
-// Draw renders volume bars synchronized with the chart's ts_from and ts_to
-func (v *Volume) Draw(screen *ebiten.Image, chart *Chart) {
-	// Calculate volume area dimensions (20% of chart height)
-	volumeHeight := (v.config.Height - v.config.TopMargin - v.config.BottomMargin) * 0.2
-	volumeTop := v.config.Height - v.config.BottomMargin - volumeHeight
-
-	// Find max volume for scaling within the displayed range
-	maxVolume := 0.0
-	for _, d := range chart.Data {
-		if d.Time >= chart.ts_from && d.Volume > maxVolume {
-			maxVolume = d.Volume
-		}
+type volumeMAKey struct {
+	lodKey
+	quote  bool
+	period int
+}
+
+type volumePoint struct {
+	x     float32
+	value float64
+}
+
+func NewVolume(config ChartConfig, layout *Layout) *Volume {
+	v := &Volume{
+		Quote:    config.VolumeQuote,
+		MAPeriod: config.VolumeMAPeriod,
+		config:   config,
+		layout:   layout,
 	}
-	if maxVolume == 0 {
-		return
+	v.band.Content = v
+	return v
+}
+
+func (v *Volume) Name() string {
+	if v.Quote {
+		return "Volume (quote)"
+	}
+	return "Volume"
+}
+
+// value returns a bar's volume in the units shown. Quote volume is
+// estimated from the typical price, as bars don't carry the exact turnover.
+func (v *Volume) value(d OHLCV) float64 {
+	if v.Quote {
+		return d.Volume * (d.High + d.Low + d.Close) / 3
+	}
+	return d.Volume
+}
+
+// Range scales the pane from zero to the largest visible volume or average
+func (v *Volume) Range(chart *Chart, start, end int) (min, max float64, ok bool) {
+	for _, d := range chart.visibleBars(start, end) {
+		max = math.Max(max, v.value(d.OHLCV))
+	}
+	for _, p := range v.movingAverage(chart, start, end) {
+		max = math.Max(max, p.value)
+	}
+	return 0, max, max > 0
+}
+
+func (v *Volume) FormatValue(value, step float64) string {
+	return formatVolume(value, step)
+}
+
+// Draw renders volume bars under the visible price bars, with the moving
+// average over them
+func (v *Volume) Draw(screen Renderer, chart *Chart, pane *Pane) {
+	start, end := chart.visibleRange()
+	if start == -1 || pane.max == 0 {
+		return // No bars to display
 	}
 
 	// Calculate bar dimensions to match OHLC bars
-	totalBarSpace := v.config.BarWidth + v.config.BarSpacing
-	volumeBarWidth := (chart.config.BarSpacing * chart.Zoom) - v.config.VolumeSpacing
+	volumeBarWidth := (chart.layout.BarSpacing * chart.Zoom) - v.layout.VolumeSpacing
 	if volumeBarWidth < 1 {
 		volumeBarWidth = 1 // Ensure minimum width
 	}
 
-	// Find the index of the first bar with Time >= ts_from
-	startIndex := -1
-	for i, d := range chart.Data {
-		if d.Time >= chart.ts_from {
-			startIndex = i
-			break
+	key := volumeKey{chart.geometryKey(start, end, pane.Top, pane.Bottom, pane.min, pane.max), v.Quote}
+	if key != v.key {
+		v.bars.reset()
+		for _, ohlcv := range chart.visibleBars(start, end) {
+			x := ohlcv.x
+			y := pane.valueToY(v.value(ohlcv.OHLCV))
+
+			var barColor color.RGBA
+			if ohlcv.Close >= ohlcv.Open {
+				barColor = v.config.VolumeUpColor
+			} else {
+				barColor = v.config.VolumeDownColor
+			}
+
+			// Volume bar centered under the corresponding OHLC bar
+			v.bars.addRect(x-float32(volumeBarWidth/2), y, float32(volumeBarWidth), float32(pane.Bottom)-y, barColor)
 		}
+		v.key = key
 	}
-	if startIndex == -1 {
-		return // No bars to display
+	screen.DrawBatch(&v.bars)
+
+	if ma := v.movingAverage(chart, start, end); len(ma) > 1 {
+		var path Path
+		for i, p := range ma {
+			if i == 0 {
+				path.MoveTo(p.x, pane.valueToY(p.value))
+			} else {
+				path.LineTo(p.x, pane.valueToY(p.value))
+			}
+		}
+		screen.StrokePath(&path, v.layout.Px32(1.5), v.config.VolumeMAColor)
 	}
+}
 
-	// Draw volume bars starting from startIndex until there's no more space
-	for i := startIndex; i < len(chart.Data); i++ {
-		ohlcv := chart.Data[i]
-		x := float32(v.config.LeftMargin + float64(i-startIndex)*totalBarSpace*chart.Zoom)
+// movingAverage returns the simple moving average of the volume over
+// MAPeriod bars for bars start..end-1, computed from every bar and sampled
+// at the last bar of each pixel column. Bars with less history than the
+// period have no average.
+func (v *Volume) movingAverage(chart *Chart, start, end int) []volumePoint {
+	if v.MAPeriod <= 0 {
+		return nil
+	}
+	key := volumeMAKey{
+		lodKey: lodKey{start: start, end: end, zoom: chart.Zoom, scroll: chart.scroll, width: chart.layout.Width, dpi: chart.layout.Scale},
+		quote:  v.Quote,
+		period: v.MAPeriod,
+	}
+	if len(chart.Data) > 0 {
+		key.data, key.last = &chart.Data[0], chart.Data[len(chart.Data)-1]
+	}
+	if key == v.maKey && v.ma != nil {
+		return v.ma
+	}
 
-		// Stop drawing if the bar exceeds the visible area
-		if x > float32(v.config.Width-v.config.RightMargin) {
-			break
+	points := v.ma[:0]
+	column := math.Inf(-1)
+	sum := 0.0
+	first := max(0, start-v.MAPeriod+1)
+	for i := first; i < end; i++ {
+		sum += v.value(chart.Data[i])
+		if i-v.MAPeriod >= first {
+			sum -= v.value(chart.Data[i-v.MAPeriod])
+		}
+		if i < start || i < v.MAPeriod-1 {
+			continue
+		}
+		p := volumePoint{chart.barX(i - start), sum / float64(v.MAPeriod)}
+		col := math.Floor(float64(p.x))
+		if col == column && len(points) > 0 {
+			points[len(points)-1] = p
+			continue
 		}
+		column = col
+		points = append(points, p)
+	}
 
-		barHeight := (ohlcv.Volume / maxVolume) * volumeHeight
-		y := volumeTop + (volumeHeight - barHeight)
+	v.ma, v.maKey = points, key
+	return points
+}
 
-		var barColor color.RGBA
-		if ohlcv.Close >= ohlcv.Open {
-			barColor = v.config.VolumeUpColor
-		} else {
-			barColor = v.config.VolumeDownColor
-		}
+// fitOverlay places the overlay band along the bottom of the price pane
+// and scales it to the visible volume
+func (v *Volume) fitOverlay(chart *Chart) {
+	main := chart.panes.Main()
+	v.band.Bottom = main.Bottom
+	v.band.Top = main.Bottom - main.Height()*v.config.VolumeOverlayHeight
+	start, end := chart.visibleRange()
+	if start == -1 || end <= start {
+		return
+	}
+	if min, max, ok := v.Range(chart, start, end); ok {
+		v.band.min, v.band.max = min, max
+	}
+}
 
-		// Draw volume bar centered under the corresponding OHLC bar
-		vector.DrawFilledRect(
-			screen,
-			x+float32((chart.config.BarSpacing*chart.Zoom-volumeBarWidth)/2),
-			float32(y),
-			float32(volumeBarWidth),
-			float32(barHeight),
-			barColor,
-			false,
-		)
+// formatVolume abbreviates a volume label with the precision step needs
+func formatVolume(value, step float64) string {
+	switch {
+	case math.Abs(value) >= 1e9:
+		return fmt.Sprintf("%.*fB", stepDecimals(step/1e9, 0), value/1e9)
+	case math.Abs(value) >= 1e6:
+		return fmt.Sprintf("%.*fM", stepDecimals(step/1e6, 0), value/1e6)
+	case math.Abs(value) >= 1e3:
+		return fmt.Sprintf("%.*fK", stepDecimals(step/1e3, 0), value/1e3)
+	default:
+		return fmt.Sprintf("%.*f", stepDecimals(step, 0), value)
 	}
 }
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"golang.org/x/image/font"
+)
+
+// ProfileKind selects the market profile drawn over the price pane
+type ProfileKind int
+
+const (
+	ProfileNone   ProfileKind = iota
+	ProfileVolume             // Volume by price over the visible range
+	ProfileTPO                // Letters per half hour by price for each session
+	profileKindCount
+)
+
+var profileKindNames = []string{"none", "volume", "tpo"}
+
+func (k ProfileKind) String() string {
+	return profileKindNames[k]
+}
+
+// parseProfileKind reads a profile kind by name
+func parseProfileKind(name string) (ProfileKind, error) {
+	for k, n := range profileKindNames {
+		if n == name {
+			return ProfileKind(k), nil
+		}
+	}
+	return ProfileNone, fmt.Errorf("unknown profile %q, use one of %s", name, strings.Join(profileKindNames, ", "))
+}
+
+// maxProfileRows caps the rows a small bucket size can produce
+const maxProfileRows = 2000
+
+// VolumeProfile is a horizontal histogram of the volume traded at each
+// price over the visible bars, drawn against the right edge of the price
+// pane. It is built from the 1-minute bars behind the visible range rather
+// than the chart's bars, so the distribution keeps intraday detail.
+type VolumeProfile struct {
+	Rows       int     // Price rows, used while BucketSize is 0
+	BucketSize float64 // Price per row, overrides Rows when set
+	minutes    *minuteCache
+	profile    volumeProfile
+	key        volumeProfileKey
+	last       OHLCV // Last bar when the minutes were last invalidated
+	fonts      *fontCache
+	config     ChartConfig
+	layout     *Layout
+}
+
+// volumeProfile is the volume per price row. Row i spans lo+i*step to
+// lo+(i+1)*step.
+type volumeProfile struct {
+	volume   []float64
+	lo, step float64
+	poc      int // Row with the most volume
+	val, vah int // Lowest and highest rows of the value area
+}
+
+type volumeProfileKey struct {
+	from, to int64
+	last     OHLCV
+	rows     int
+	bucket   float64
+}
+
+func NewVolumeProfile(config ChartConfig, layout *Layout, minutes *minuteCache) *VolumeProfile {
+	return &VolumeProfile{
+		Rows:       config.VolumeProfileRows,
+		BucketSize: config.VolumeProfileBucket,
+		minutes:    minutes,
+		fonts:      newFontCache(11),
+		config:     config,
+		layout:     layout,
+	}
+}
+
+// PriceRange leaves the fit to the bars, which the profile spans anyway
+func (p *VolumeProfile) PriceRange(from, to int64) (min, max float64, ok bool) {
+	return 0, 0, false
+}
+
+// update rebuilds the profile when the visible range or the data changes
+func (p *VolumeProfile) update(chart *Chart, start, end int) *volumeProfile {
+	key := volumeProfileKey{
+		from:   chart.Data[start].Time,
+		to:     chart.Data[end-1].Time + chart.barInterval(),
+		last:   chart.Data[len(chart.Data)-1],
+		rows:   p.Rows,
+		bucket: p.BucketSize,
+	}
+	if key == p.key {
+		return &p.profile
+	}
+	// The forming bar's minutes are still arriving
+	if key.last != p.last {
+		p.minutes.invalidate(key.last.Time)
+		p.last = key.last
+	}
+	minutes, ok := p.minutes.between(key.from, key.to)
+	if !ok {
+		return &p.profile // The previous profile until the minutes are read
+	}
+	p.profile = buildVolumeProfile(minutes, p.Rows, p.BucketSize, p.config.VolumeProfileValueArea)
+	p.key = key
+	return &p.profile
+}
+
+// buildVolumeProfile spreads each minute's volume evenly over its high-low
+// range and finds the point of control and the value area holding
+// valueArea of the volume
+func buildVolumeProfile(minutes []OHLCV, rows int, bucket, valueArea float64) volumeProfile {
+	if len(minutes) == 0 {
+		return volumeProfile{}
+	}
+	lo, hi := calculatePriceRange(minutes)
+	step := bucket
+	if step > 0 {
+		lo = math.Floor(lo/step) * step
+	} else {
+		step = (hi - lo) / float64(max(rows, 1))
+	}
+	if step <= 0 {
+		step = math.Max(math.Abs(hi)*1e-4, 1e-8) // Flat prices fill one row
+	}
+	n := min(maxProfileRows, max(1, int(math.Ceil((hi-lo)/step-1e-9))))
+	step = math.Max(step, (hi-lo)/float64(n))
+
+	p := volumeProfile{volume: make([]float64, n), lo: lo, step: step}
+	row := func(price float64) int {
+		return max(0, min(n-1, int((price-lo)/step)))
+	}
+	for _, m := range minutes {
+		first, last := row(m.Low), row(m.High)
+		if first == last || m.High <= m.Low {
+			p.volume[first] += m.Volume
+			continue
+		}
+		for r := first; r <= last; r++ {
+			rowLo, rowHi := lo+float64(r)*step, lo+float64(r+1)*step
+			overlap := math.Min(rowHi, m.High) - math.Max(rowLo, m.Low)
+			p.volume[r] += m.Volume * math.Max(0, overlap) / (m.High - m.Low)
+		}
+	}
+
+	total := 0.0
+	for r, v := range p.volume {
+		total += v
+		if v > p.volume[p.poc] {
+			p.poc = r
+		}
+	}
+
+	p.val, p.vah = findValueArea(p.volume, p.poc, total*valueArea)
+	return p
+}
+
+// findValueArea grows a band of rows from the point of control towards the
+// busier neighbour until it holds target, and returns its lowest and
+// highest rows
+func findValueArea(rows []float64, poc int, target float64) (lo, hi int) {
+	lo, hi = poc, poc
+	sum := rows[poc]
+	for sum < target && (lo > 0 || hi < len(rows)-1) {
+		below, above := -1.0, -1.0
+		if lo > 0 {
+			below = rows[lo-1]
+		}
+		if hi < len(rows)-1 {
+			above = rows[hi+1]
+		}
+		if above >= below {
+			hi++
+			sum += above
+		} else {
+			lo--
+			sum += below
+		}
+	}
+	return lo, hi
+}
+
+// Draw renders the histogram with the value area and point of control
+// highlighted, and lines across the pane at the POC, VAH and VAL
+func (p *VolumeProfile) Draw(screen Renderer, chart *Chart) {
+	start, end := chart.visibleRange()
+	if start == -1 || end <= start {
+		return
+	}
+	profile := p.update(chart, start, end)
+	peak := 0.0
+	for _, v := range profile.volume {
+		peak = math.Max(peak, v)
+	}
+	if peak == 0 {
+		return
+	}
+
+	left, right := p.layout.LeftMargin, p.layout.Width-p.layout.RightMargin
+	maxWidth := (right - left) * p.config.VolumeProfileWidth
+	gap := float32(0)
+	if rowHeight := math.Abs(float64(chart.priceToY(profile.lo) - chart.priceToY(profile.lo+profile.step))); rowHeight > p.layout.Px(3) {
+		gap = p.layout.Px32(1)
+	}
+	for r, v := range profile.volume {
+		y0 := chart.priceToY(profile.lo + float64(r+1)*profile.step)
+		y1 := chart.priceToY(profile.lo + float64(r)*profile.step)
+		top, bottom := min32(y0, y1), max32(y0, y1)
+		width := float32(v / peak * maxWidth)
+
+		clr := p.config.ProfileColor
+		switch {
+		case r == profile.poc:
+			clr = p.config.ProfilePOCColor
+		case r >= profile.val && r <= profile.vah:
+			clr = p.config.ProfileValueAreaColor
+		}
+		screen.FillRect(float32(right)-width, top, width, bottom-top-gap, clr)
+	}
+
+	face := p.fonts.get(p.layout)
+	p.drawLevel(screen, chart, face, profile.lo+(float64(profile.poc)+0.5)*profile.step, "POC", false)
+	p.drawLevel(screen, chart, face, profile.lo+float64(profile.vah+1)*profile.step, "VAH", true)
+	p.drawLevel(screen, chart, face, profile.lo+float64(profile.val)*profile.step, "VAL", true)
+}
+
+// drawLevel draws a line across the price pane at price, labelled at the
+// right edge
+func (p *VolumeProfile) drawLevel(screen Renderer, chart *Chart, face font.Face, price float64, label string, dashed bool) {
+	left, right := float32(p.layout.LeftMargin), float32(p.layout.Width-p.layout.RightMargin)
+	y := chart.priceToY(price)
+	clr := p.config.ProfilePOCColor
+	if dashed {
+		dash := p.layout.Px32(4)
+		for x := left; x < right; x += dash * 2 {
+			screen.StrokeLine(x, y, min32(x+dash, right), y, p.layout.Px32(1), clr)
+		}
+	} else {
+		screen.StrokeLine(left, y, right, y, p.layout.Px32(1), clr)
+	}
+	width := font.MeasureString(face, label).Ceil()
+	screen.Text(label, face, int(right)-width-int(p.layout.Px(4)), int(y)-int(p.layout.Px(3)), clr)
+}